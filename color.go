@@ -12,7 +12,8 @@ var (
 	colorNormal, colorFile, colorDir, colorLink, colorPipe, colorSocket                 string
 	colorBlockDev, colorCharDev, colorOrphan, colorExec                                 string
 	colorDoor, colorSuid, colorSgid, colorSticky, colorOtherWrite, colorOtherWriteStick string
-	colorHidden                                                                         string
+	colorHidden, colorHeader, colorSparse, colorCap, colorMultiHardlink                 string
+	colorSuspicious                                                                     string
 	reset                                                                               string
 
 	systemStyle = func() string {
@@ -41,6 +42,8 @@ func setColor() {
 	}
 
 	reset = zli.Reset.String()
+	colorHeader = zli.Underline.String()
+	colorSparse = zli.Dim.String()
 
 	style := systemStyle
 	for _, v := range ellesColors {
@@ -50,6 +53,14 @@ func setColor() {
 			zli.Errorf("invalid value in ELLES_COLORS: %q", v)
 		case strings.HasPrefix(v, "hidden="):
 			colorHidden = "\x1b[" + v[7:] + "m"
+		case strings.HasPrefix(v, "header="):
+			colorHeader = "\x1b[" + v[7:] + "m"
+		case v == "sparse=":
+			colorSparse = ""
+		case strings.HasPrefix(v, "sparse="):
+			colorSparse = "\x1b[" + v[7:] + "m"
+		case strings.HasPrefix(v, "suspicious="):
+			colorSuspicious = "\x1b[" + v[11:] + "m"
 		case v == "bsd":
 			style = "bsd"
 		case v == "gnu":
@@ -213,6 +224,12 @@ func readGNUColors() bool {
 			colorSuid = "\x1b[" + v + "m"
 		case "sg":
 			colorSgid = "\x1b[" + v + "m"
+		case "ca":
+			colorCap = "\x1b[" + v + "m"
+		case "mh":
+			if v != "0" && v != "00" {
+				colorMultiHardlink = "\x1b[" + v + "m"
+			}
 		case "st":
 			colorSticky = "\x1b[" + v + "m"
 		case "ow":
@@ -18,7 +18,8 @@ func clearColors() {
 		&colorNormal, &colorFile, &colorDir, &colorLink, &colorPipe, &colorSocket,
 		&colorBlockDev, &colorCharDev, &colorOrphan, &colorExec, &colorDoor,
 		&colorSuid, &colorSgid, &colorSticky, &colorOtherWrite,
-		&colorOtherWriteStick, &reset,
+		&colorOtherWriteStick, &colorHeader, &colorSparse, &colorCap,
+		&colorMultiHardlink, &colorSuspicious, &reset,
 	} {
 		*c = ""
 	}
@@ -262,64 +263,93 @@ func TestDefaultColor(t *testing.T) {
 // 	// compare exp out
 // })
 
-// t.Run("multihardlink", func(t *testing.T) {
-// 	// Ensure "ls --color" properly colors names of hard linked files.
-// 	start(t)
+func TestMultiHardlink(t *testing.T) {
+	// Ensure "ls --color" properly colors names of hard linked files.
+	defer clearColors()
+	start(t)
+	touch(t, "file")
+	touch(t, "file1")
+	if err := os.Link("file1", "file2"); err != nil {
+		t.Skip("can't create hard link:", err)
+	}
 
-// 	// touch file file1
-// 	// ln file1 file2 || skip_ "can't create hard link"
-// 	// code_mh='44;37'
-// 	// code_ex='01;32'
-// 	// code_png='01;35'
-// 	// c0=$(printf '\033[0m')
-// 	// c_mh=$(printf '\033[%sm' $code_mh)
-// 	// c_ex=$(printf '\033[%sm' $code_ex)
-// 	// c_png=$(printf '\033[%sm' $code_png)
-
-// 	// # regular file - not hard linked
-// 	// LS_COLORS="mh=$code_mh" ls -U1 --color=always file > out
-// 	// printf "file\n" > out_ok
-// 	// compare out out_ok
-
-// 	// # hard links
-// 	// LS_COLORS="mh=$code_mh" ls -U1 --color=always file1 file2 > out
-// 	// printf "$c0${c_mh}file1$c0
-// 	// ${c_mh}file2$c0
-// 	// " > out_ok
-// 	// compare out out_ok
-
-// 	// # hard links and png (hard link coloring takes precedence)
-// 	// mv file2 file2.png
-// 	// LS_COLORS="mh=$code_mh:*.png=$code_png" ls -U1 --color=always file1 file2.png \
-// 	//   > out
-// 	// printf "$c0${c_mh}file1$c0
-// 	// ${c_mh}file2.png$c0
-// 	// " > out_ok
-// 	// compare out out_ok
-
-// 	// # hard links and exe (exe coloring takes precedence)
-// 	// chmod a+x file2.png
-// 	// LS_COLORS="mh=$code_mh:*.png=$code_png:ex=$code_ex" \
-// 	//   ls -U1 --color=always file1 file2.png > out
-// 	// chmod a-x file2.png
-// 	// printf "$c0${c_ex}file1$c0
-// 	// ${c_ex}file2.png$c0
-// 	// " > out_ok
-// 	// compare out out_ok
-
-// 	// # hard links and png (hard link coloring disabled => png coloring enabled)
-// 	// LS_COLORS="mh=00:*.png=$code_png" ls -U1 --color=always file1 file2.png > out \
-
-// 	// printf "file1
-// 	// $c0${c_png}file2.png$c0
-// 	// " > out_ok
-// 	// compare out out_ok
-
-// 	// # hard links and png (hard link coloring not enabled explicitly => png coloring)
-// 	// LS_COLORS="*.png=$code_png" ls -U1 --color=always file1 file2.png > out \
-
-// 	// printf "file1
-// 	// $c0${c_png}file2.png$c0
-// 	// " > out_ok
-// 	// compare out out_ok
-// })
+	t.Setenv("LS_COLORS", "mh=44;37")
+	zli.WantColor = true
+	setColor()
+
+	// Regular file - not hard linked.
+	have := mustRun(t, "-1", "--color=always", "file")
+	if want := "file"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+
+	// Hard links.
+	have = mustRun(t, "-1", "--color=always", "file1", "file2")
+	if want := "\x1b[44;37mfile1\x1b[0m\n\x1b[44;37mfile2\x1b[0m"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+
+	// Hard links and exe (exe coloring takes precedence). Windows has no
+	// exec bits.
+	if runtime.GOOS != "windows" {
+		t.Setenv("LS_COLORS", "mh=44;37:ex=01;32")
+		clearColors()
+		zli.WantColor = true
+		setColor()
+		chmod(t, 0o755, "file2")
+		have = mustRun(t, "-1", "--color=always", "file1", "file2")
+		if want := "\x1b[01;32mfile1\x1b[0m\n\x1b[01;32mfile2\x1b[0m"; have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+		chmod(t, 0o644, "file2")
+	}
+
+	// Hard link coloring disabled.
+	t.Setenv("LS_COLORS", "mh=00")
+	clearColors()
+	zli.WantColor = true
+	setColor()
+	have = mustRun(t, "-1", "--color=always", "file1", "file2")
+	if want := "file1\nfile2"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestSuspiciousColor(t *testing.T) {
+	defer clearColors()
+	start(t)
+	touch(t, "file")
+	touch(t, "-rf")
+	t.Setenv("LS_COLORS", "fi=00")
+
+	have := mustRun(t, "-1", "--color=always", "-suspicious")
+	if want := "leading-dash \x1b[00m\x1b[37;41m-rf\x1b[0m\n             \x1b[00mfile\x1b[0m"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+
+	// Only the colour from ELLES_COLORS, without the column.
+	clearColors()
+	t.Setenv("ELLES_COLORS", "gnu:suspicious=01;31")
+	ellesColors = strings.Split(os.Getenv("ELLES_COLORS"), ":")
+	defer func() { ellesColors = []string{systemStyle} }()
+	have = mustRun(t, "-1", "--color=always")
+	if want := "\x1b[00m\x1b[01;31m-rf\x1b[0m\n\x1b[00mfile\x1b[0m"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestDeviceColor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+	defer clearColors()
+	t.Setenv("LS_COLORS", "bd=31:cd=32")
+	zli.WantColor = true
+	setColor()
+
+	// Character devices also have fs.ModeDevice set.
+	have := mustRun(t, "-1", "--color=always", "/dev/null")
+	if want := "\x1b[32m/dev/null\x1b[0m"; have != want {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Get the reasons a filename may be deceptive, or nil if it looks fine. This is
+// a cheap heuristic to spot names that look like something else, e.g. in
+// directories with uploaded files:
+//
+//	bidi          Bidirectional control characters that reorder text, e.g.
+//	              "txt.exe" with U+202E displays as "exe.txt".
+//	invisible     Zero-width and other invisible characters.
+//	leading-dash  Can be interpreted as a flag by many commands.
+//	trailing-dot  Trailing dots or spaces, which Windows strips.
+//	homoglyph     Letters from different scripts in one word (e.g. "pаypal"
+//	              with a Cyrillic "а"), or lookalikes of "/" and ".".
+//
+// The bidi and invisible characters are already escaped when displaying the
+// name, so these are mostly useful for the -suspicious column.
+func deceptive(name string) []string {
+	var (
+		reasons                    []string
+		bidi, invisible, homoglyph bool
+		script                     *unicode.RangeTable // Script of the current word.
+	)
+	for _, r := range name {
+		switch {
+		case isBidi(r):
+			bidi = true
+		case isInvisible(r):
+			invisible = true
+		case isLookalike(r):
+			homoglyph = true
+		}
+
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) {
+			script = nil
+			continue
+		}
+		if s := letterScript(r); s != nil {
+			if script != nil && script != s {
+				homoglyph = true
+			}
+			script = s
+		}
+	}
+
+	if bidi {
+		reasons = append(reasons, "bidi")
+	}
+	if invisible {
+		reasons = append(reasons, "invisible")
+	}
+	if strings.HasPrefix(name, "-") {
+		reasons = append(reasons, "leading-dash")
+	}
+	if l := len(name); l > 1 && name != ".." && (name[l-1] == '.' || name[l-1] == ' ') {
+		reasons = append(reasons, "trailing-dot")
+	}
+	if homoglyph {
+		reasons = append(reasons, "homoglyph")
+	}
+	return reasons
+}
+
+// Scripts with letters that are easily confused with Latin letters.
+var confusableScripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic,
+	unicode.Greek, unicode.Armenian, unicode.Cherokee}
+
+func letterScript(r rune) *unicode.RangeTable {
+	if r < utf8.RuneSelf {
+		return unicode.Latin
+	}
+	for _, s := range confusableScripts {
+		if unicode.Is(s, r) {
+			return s
+		}
+	}
+	return nil
+}
+
+func isBidi(r rune) bool {
+	return unicode.Is(unicode.Bidi_Control, r)
+}
+
+func isInvisible(r rune) bool {
+	switch r {
+	case 0x00ad, 0x034f, 0x115f, 0x1160, 0x180e, 0x3164, 0xfeff, 0xffa0:
+		return true
+	}
+	return (r >= 0x200b && r <= 0x200d) || (r >= 0x2060 && r <= 0x2064) ||
+		isVariationSelector(r) || (r >= 0xe0000 && r <= 0xe007f)
+}
+
+// Lookalikes for "/" and ".", and the fullwidth ASCII forms.
+func isLookalike(r rune) bool {
+	switch r {
+	case 0x2215, 0x2044, 0x29f8, 0x2571, 0x2024, 0x3002, 0xff61:
+		return true
+	}
+	return r >= 0xff01 && r <= 0xff5e
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Thousands separator and decimal point for -,
+type numFormat struct {
+	sep, point string
+	indian     bool // Group as 12,34,567 rather than 1,234,567.
+}
+
+// Separators for locales that don't use 1,234.5; this isn't complete, but
+// should cover the most common ones. The key is either the language or
+// language_TERRITORY.
+//
+// Most locales use a narrow no-break space (U+202F) rather than a space, but
+// this makes it hard to copy/paste and it doesn't always show well in
+// terminals.
+var numFormats = func() map[string]numFormat {
+	var (
+		dot   = numFormat{".", ",", false}
+		space = numFormat{" ", ",", false}
+		apos  = numFormat{"'", ".", false}
+		comma = numFormat{",", ".", false}
+		lakh  = numFormat{",", ".", true}
+	)
+	m := map[string]numFormat{
+		"de_CH": apos, "de_LI": apos, "it_CH": apos, "fr_CH": space,
+		"es_MX": comma, "es_US": comma, "pt_PT": space, "en_ZA": space,
+		"en_IN": lakh,
+	}
+	for _, l := range []string{"de", "nl", "it", "es", "pt", "id", "tr", "da",
+		"el", "ro", "sl", "hr", "sr", "bs", "vi", "is"} {
+		m[l] = dot
+	}
+	for _, l := range []string{"fr", "ru", "pl", "cs", "sk", "sv", "fi", "nb",
+		"nn", "no", "uk", "hu", "bg", "et", "lv", "lt", "be", "kk"} {
+		m[l] = space
+	}
+	for _, l := range []string{"hi", "bn", "mr", "ta", "te", "gu", "kn", "ml",
+		"pa", "or", "as", "ne"} {
+		m[l] = lakh
+	}
+	return m
+}()
+
+// Get the number format for a locale such as "de_DE.UTF-8"; if locale is empty
+// it's read from the environment. Unknown locales use "1,234.5".
+func localeNumFormat(locale string) numFormat {
+	if locale == "" {
+		for _, k := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+			if locale = os.Getenv(k); locale != "" {
+				break
+			}
+		}
+	}
+	// Remove ".UTF-8" encoding and "@euro" modifier.
+	if i := strings.IndexAny(locale, ".@"); i > -1 {
+		locale = locale[:i]
+	}
+	locale = strings.ReplaceAll(locale, "-", "_")
+
+	if f, ok := numFormats[locale]; ok {
+		return f
+	}
+	lang, _, _ := strings.Cut(locale, "_")
+	if f, ok := numFormats[strings.ToLower(lang)]; ok {
+		return f
+	}
+	return numFormat{",", ".", false}
+}
+
+// Group digits if -, is given; nf is nil if it's not.
+func (nf *numFormat) group(s string) string {
+	if nf == nil {
+		return s
+	}
+	return groupDigits(s, *nf)
+}
+
+// Group digits in s, which can have a decimal part and unit suffix such as
+// "1234.5K".
+func groupDigits(s string, nf numFormat) string {
+	i, frac, hasFrac := strings.Cut(s, ".")
+	if hasFrac {
+		frac = nf.point + frac
+	}
+	// Don't group the unit suffix (K, kB, MiB, etc.)
+	if j := strings.LastIndexAny(i, "0123456789") + 1; j < len(i) {
+		frac += i[j:]
+		i = i[:j]
+	}
+
+	var (
+		groups []string // In reverse order.
+		end    = len(i)
+		size   = 3
+	)
+	for end > size {
+		groups = append(groups, i[end-size:end])
+		end -= size
+		if nf.indian {
+			size = 2
+		}
+	}
+	if len(groups) == 0 {
+		return i + frac
+	}
+
+	var b strings.Builder
+	b.Grow(len(i) + len(groups)*len(nf.sep) + len(frac))
+	b.WriteString(i[:end])
+	for j := len(groups) - 1; j >= 0; j-- {
+		b.WriteString(nf.sep)
+		b.WriteString(groups[j])
+	}
+	b.WriteString(frac)
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"zgo.at/zli"
+)
+
+// Count the bytes written, for the -dired offsets.
+type countWriter struct {
+	w *bufio.Writer
+	n int
+}
+
+func (w *countWriter) WriteString(s string) {
+	n, _ := w.w.WriteString(s)
+	w.n += n
+}
+
+// Print -ll listings in the format Emacs dired expects from "ls -l --dired":
+// every line is indented by two spaces, and the output ends with the byte
+// offsets of the start and end of every name:
+//
+//	  total 8
+//	  -rw-r--r-- 1 martin martin 1234 Jun 10 13:14 file
+//	  lrwxrwxrwx 1 martin martin    4 Jun 10 13:14 link -> file
+//	//DIRED// 57 61 109 113
+//	//DIRED-OPTIONS// --quoting-style=literal
+//
+// The directory headers are listed in //SUBDIRED// if there's more than one
+// directory. The names are written as-is, unless -quoting-style is given.
+func printDired(toPrint []printable, errs *errGroup, opt opts) {
+	var (
+		w               = &countWriter{w: bufio.NewWriter(zli.Stdout)}
+		dired, subdired []int
+	)
+	opt.header = true
+	for i, p := range toPrint {
+		if len(toPrint) > 1 && p.dir != "" {
+			if i > 0 {
+				w.WriteString("\n")
+			}
+			w.WriteString("  ")
+			subdired = append(subdired, w.n)
+			w.WriteString(filepath.ToSlash(filepath.Clean(p.dir)))
+			subdired = append(subdired, w.n)
+			w.WriteString(":\n")
+		}
+		if !p.isFiles {
+			var blocks int64
+			for _, fi := range p.fi {
+				if b := fi.Blocks(); b > 0 { // -1 if there's no stat data.
+					blocks += b
+				}
+			}
+			w.WriteString("  total " + strconv.FormatInt((blocks+1)/2, 10) + "\n")
+		}
+
+		// The header is only used to find the name column; don't include it
+		// in the widths.
+		cc := getCols(p, opt)
+		nameCol := nameColumn(cc.header)
+		clear(cc.longest)
+		for _, r := range cc.rows {
+			for j := range r {
+				cc.longest[j] = max(cc.longest[j], r[j].w)
+			}
+		}
+		var buf strings.Builder
+		for _, r := range cc.rows {
+			buf.Reset()
+			_, s, e := formatRow(&buf, r, cc.longest, nameCol)
+			w.WriteString("  ")
+			dired = append(dired, w.n+s, w.n+e)
+			w.WriteString(buf.String() + "\n")
+		}
+	}
+
+	w.WriteString("//DIRED//" + diredOffsets(dired) + "\n")
+	if len(subdired) > 0 {
+		w.WriteString("//SUBDIRED//" + diredOffsets(subdired) + "\n")
+	}
+	style := opt.quote.style
+	if style == "" {
+		style = "literal"
+	}
+	w.WriteString("//DIRED-OPTIONS// --quoting-style=" + style + "\n")
+	zli.F(w.w.Flush())
+	printErrors(errs)
+}
+
+func diredOffsets(o []int) string {
+	var b strings.Builder
+	for _, n := range o {
+		fmt.Fprintf(&b, " %d", n)
+	}
+	return b.String()
+}
+
+// Names for -dired are as-is, as dired doesn't know about our quoting.
+func diredQuote(s string, opt opts) string {
+	if opt.quote.style == "" {
+		return s
+	}
+	return opt.quote.quote(s)
+}
+
+// Format the time like ls: with the year instead of the time for times more
+// than six months ago or in the future.
+func lsTime(t time.Time) string {
+	if d := time.Since(t); d < 0 || d > 182*24*time.Hour {
+		return t.Format("Jan _2  2006")
+	}
+	return t.Format("Jan _2 15:04")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"zgo.at/elles/os2"
+)
+
+type (
+	duSize struct{ apparent, alloc int64 }
+
+	// Calculate the total size of directory trees for -du.
+	//
+	// Every directory is walked only once: the result for all subdirectories
+	// is cached, so that listing them with -R doesn't walk them again.
+	duWalker struct {
+		oneFS   bool
+		errs    *errGroup
+		sem     chan struct{}
+		device  func(fs.FileInfo) uint64            // os2.Device; replaced in tests.
+		readDir func(string) ([]os.DirEntry, error) // os2.ReadDir; replaced in tests.
+
+		mu    sync.Mutex
+		cache map[string]*duEntry
+	}
+	duEntry struct {
+		once sync.Once
+		// Size of everything except files with more than one link, which are
+		// in links so they're counted only once for every directory tree.
+		base  duSize
+		links map[[2]uint64]duSize
+	}
+)
+
+func newDuWalker(errs *errGroup, oneFS bool) *duWalker {
+	return &duWalker{
+		oneFS:   oneFS,
+		errs:    errs,
+		sem:     make(chan struct{}, runtime.GOMAXPROCS(0)*2),
+		device:  os2.Device,
+		readDir: os2.ReadDir,
+		cache:   make(map[string]*duEntry),
+	}
+}
+
+func (e *duEntry) total() duSize {
+	t := e.base
+	for _, l := range e.links {
+		t.apparent += l.apparent
+		t.alloc += l.alloc
+	}
+	return t
+}
+
+// Set the recursive size on all directories in toPrint.
+func (d *duWalker) fill(toPrint []printable) {
+	var wg sync.WaitGroup
+	for _, p := range toPrint {
+		for i, fi := range p.fi {
+			if !fi.IsDir() {
+				continue
+			}
+			path := p.abs(fi)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s := d.get(path, d.device(fi)).total()
+				s.apparent += fi.FileInfo.Size()
+				s.alloc += os2.Blocks(fi) * 512
+				p.fi[i].du = &s
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// Get the directory at path, walking it if it's not in the cache yet.
+func (d *duWalker) get(path string, dev uint64) *duEntry {
+	d.mu.Lock()
+	e, ok := d.cache[path]
+	if !ok {
+		e = &duEntry{}
+		d.cache[path] = e
+	}
+	d.mu.Unlock()
+
+	e.once.Do(func() { d.walk(e, path, dev) })
+	return e
+}
+
+func (d *duWalker) walk(e *duEntry, path string, dev uint64) {
+	ls, err := d.readDir(path)
+	if d.errs.Append(err) {
+		return
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, l := range ls {
+		fi, err := l.Info()
+		if d.errs.Append(err) {
+			continue
+		}
+		if d.oneFS && d.device(fi) != dev {
+			continue
+		}
+
+		if fi.IsDir() {
+			p := filepath.Join(path, fi.Name())
+			add := func() {
+				sub := d.get(p, dev)
+				mu.Lock()
+				defer mu.Unlock()
+				e.base.apparent += fi.Size() + sub.base.apparent
+				e.base.alloc += os2.Blocks(fi)*512 + sub.base.alloc
+				for k, v := range sub.links {
+					if e.links == nil {
+						e.links = make(map[[2]uint64]duSize)
+					}
+					e.links[k] = v
+				}
+			}
+
+			// Walk in a new goroutine if there's room, or in this one if there
+			// isn't.
+			select {
+			case d.sem <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer func() { <-d.sem; wg.Done() }()
+					add()
+				}()
+			default:
+				add()
+			}
+			continue
+		}
+
+		s := duSize{fi.Size(), os2.Blocks(fi) * 512}
+		mu.Lock()
+		if k, ok := os2.Hardlink(fi); ok {
+			if e.links == nil {
+				e.links = make(map[[2]uint64]duSize)
+			}
+			e.links[k] = s
+		} else {
+			e.base.apparent += s.apparent
+			e.base.alloc += s.alloc
+		}
+		mu.Unlock()
+	}
+	wg.Wait()
+}
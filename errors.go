@@ -5,9 +5,10 @@ import (
 )
 
 type errGroup struct {
-	MaxSize int
-	mu      sync.Mutex
-	errs    []error
+	MaxSize  int
+	OnAppend func(error) // Called for every error as it's appended, if set.
+	mu       sync.Mutex
+	errs     []error
 }
 
 func (g *errGroup) Len() int { return len(g.errs) }
@@ -43,6 +44,9 @@ func (g *errGroup) Append(err error) bool {
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	if g.OnAppend != nil {
+		g.OnAppend(err)
+	}
 	if g.MaxSize == 0 || len(g.errs) < g.MaxSize {
 		g.errs = append(g.errs, err)
 	}
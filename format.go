@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"zgo.at/elles/os2"
+	"zgo.at/zli"
+)
+
+// Print the columns from getCols as CSV or TSV for -format, with a header row.
+// A "Directory" column is added if dirCol is set, which should depend only on
+// the flags and not on what's listed, so the columns are always the same for
+// the same command. It's empty for files given on the commandline.
+func printCSV(toPrint []printable, errs *errGroup, opt opts, sep rune, dirCol bool) {
+	w := csv.NewWriter(zli.Stdout)
+	w.Comma = sep
+	opt.header = true
+
+	wroteHeader := false
+	for _, p := range toPrint {
+		cc := getCols(p, opt)
+		if !wroteHeader && len(cc.header) > 0 {
+			h := make([]string, 0, len(cc.header)+1)
+			if dirCol {
+				h = append(h, "Directory")
+			}
+			for _, c := range cc.header {
+				h = append(h, c.s)
+			}
+			zli.F(w.Write(h))
+			wroteHeader = true
+		}
+		for _, r := range cc.rows {
+			row := make([]string, 0, len(r)+1)
+			if dirCol {
+				row = append(row, p.dir)
+			}
+			for _, c := range r {
+				row = append(row, c.s)
+			}
+			zli.F(w.Write(row))
+		}
+	}
+	w.Flush()
+	zli.F(w.Error())
+	printErrors(errs)
+}
+
+// Entry for -format templates.
+type tplEntry struct {
+	Name       string      // Name of the entry.
+	Dir        string      // Directory as given on the commandline.
+	Path       string      // Absolute path.
+	Type       string      // file, dir, symlink, pipe, socket, char_device, block_device, door, or other.
+	Mode       fs.FileMode // Type and permission bits.
+	Size       int64       // Size in bytes.
+	Blocks     int64       // Allocated 512-byte blocks.
+	Inode      uint64
+	Links      uint64
+	UID, GID   string
+	User       string
+	Group      string
+	ModTime    time.Time // Times are zero if they're not known.
+	AccessTime time.Time
+	BirthTime  time.Time
+	LinkTarget string // Only for symlinks.
+	Hidden     bool
+
+	p  printable
+	fi fileInfo
+}
+
+func newTplEntry(p printable, fi fileInfo) tplEntry {
+	absdir := p.absdir
+	if p.isFiles {
+		absdir = fi.filepathAbs
+	}
+	e := tplEntry{
+		Name:       fi.Name(),
+		Dir:        p.dir,
+		Path:       p.abs(fi),
+		Type:       fileType(fi.Mode(), fi),
+		Mode:       fi.Mode(),
+		Size:       fi.Size(),
+		Blocks:     fi.Blocks(),
+		Inode:      os2.Serial(absdir, fi),
+		Links:      os2.Numlinks(absdir, fi),
+		ModTime:    fi.ModTime(),
+		AccessTime: os2.Atime(fi),
+		BirthTime:  os2.Btime(absdir, fi),
+		LinkTarget: linkTarget(p.abs(fi), fi),
+		Hidden:     os2.Hidden(absdir, fs.FileInfoToDirEntry(fi)),
+		p:          p,
+		fi:         fi,
+	}
+	e.UID, e.GID = os2.OwnerID(absdir, fi)
+	e.User, e.Group = userName(e.UID), groupName(e.GID)
+	return e
+}
+
+// Parse a -format template. Outside of {{ .. }} actions \t and \n are
+// replaced with a tab and newline, as it's awkward to use them in most shells.
+//
+// The helper functions use opt when they're called, rather than when the
+// template is parsed.
+func parseTemplate(s string, opt *opts) (*template.Template, error) {
+	var (
+		b    strings.Builder
+		repl = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`)
+	)
+	for {
+		i := strings.Index(s, "{{")
+		if i == -1 {
+			b.WriteString(repl.Replace(s))
+			break
+		}
+		j := strings.Index(s[i:], "}}")
+		if j == -1 {
+			b.WriteString(repl.Replace(s[:i]) + s[i:])
+			break
+		}
+		b.WriteString(repl.Replace(s[:i]) + s[i:i+j+2])
+		s = s[i+j+2:]
+	}
+	s = b.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+
+	color := func(e tplEntry) string {
+		fp, afp := e.p.dir, e.p.absdir
+		if e.p.isFiles {
+			fp, afp = e.fi.filepath, e.fi.filepathAbs
+		}
+		s, _ := decoratePath(fp, afp, e.fi, *opt, false, !e.p.isFiles)
+		return s
+	}
+	return template.New("format").Funcs(template.FuncMap{
+		"human":     func(n int64) string { return fmtSize(n, opt.size, opt.digits) },
+		"listSize":  func(e tplEntry) string { s, _ := listSize(e.fi, e.p.absdir, *opt); return s },
+		"since":     since,
+		"shortTime": func(t time.Time) string { return shortTime("", t) },
+		"strmode":   strmode,
+		"quote":     func(s string) string { return opt.quote.quote(s) },
+		"color":     color,
+		"colour":    color,
+	}).Parse(s)
+}
+
+// Print every entry with a -format template.
+func printTemplate(toPrint []printable, errs *errGroup, tpl *template.Template) {
+	w := bufio.NewWriter(zli.Stdout)
+	for _, p := range toPrint {
+		for _, fi := range p.fi {
+			if err := tpl.Execute(w, newTplEntry(p, fi)); err != nil {
+				w.Flush()
+				zli.Fatalf("-format: %s", err)
+			}
+		}
+	}
+	zli.F(w.Flush())
+	printErrors(errs)
+}
+
+// Format the time since t, as "5 minutes ago" or "in 2 days" for times in the
+// future. Zero times are "?".
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "?"
+	}
+	d := time.Since(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 7*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), "day"
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(7*24*time.Hour)), "week"
+	case d < 365*24*time.Hour:
+		n, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		n, unit = int(d/(365*24*time.Hour)), "year"
+	}
+	s := plural(n, nil, unit, unit+"s")
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// Print the raw paths for -0, terminated by a NUL byte. Paths are relative to
+// the commandline arguments, or absolute with -abs. Paths starting with "-" get
+// a "./" prefix so they're not seen as flags by e.g. "xargs -0 rm".
+func printNUL(toPrint []printable, errs *errGroup, abs bool) {
+	w := bufio.NewWriter(zli.Stdout)
+	for _, p := range toPrint {
+		for _, fi := range p.fi {
+			var path string
+			switch {
+			case abs:
+				path = p.abs(fi)
+			case p.isFiles:
+				path = filepath.Join(fi.filepath, fi.Name())
+			default:
+				path = filepath.Join(p.dir, fi.Name())
+			}
+			if strings.HasPrefix(path, "-") {
+				path = "./" + path
+			}
+			w.WriteString(path)
+			w.WriteByte(0)
+		}
+	}
+	zli.F(w.Flush())
+	printErrors(errs)
+}
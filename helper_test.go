@@ -20,6 +20,11 @@ func init() {
 	zli.WantColor = false
 	os.Unsetenv("LS_COLORS")
 	os.Unsetenv("LSCOLORS")
+	os.Unsetenv("LC_ALL")
+	os.Unsetenv("LC_NUMERIC")
+	os.Unsetenv("LANG")
+	os.Unsetenv("LC_CTYPE")
+	os.Unsetenv("QUOTING_STYLE")
 	os.Setenv("COLUMNS", "80")
 	columns = 80
 }
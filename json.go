@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"zgo.at/elles/os2"
+	"zgo.at/zli"
+)
+
+// Version of the JSON output; increment on incompatible changes.
+const jsonVersion = 1
+
+type (
+	jsonOutput struct {
+		Version  int           `json:"version"`
+		Errors   []jsonError   `json:"errors"`
+		Listings []jsonListing `json:"listings"`
+	}
+	jsonListing struct {
+		jsonDir
+		Entries []jsonEntry `json:"entries"`
+	}
+	jsonDir struct {
+		Dir      string `json:"dir"`
+		AbsDir   string `json:"abs_dir"`
+		IsFiles  bool   `json:"is_files"` // Files given on the commandline, rather than a directory.
+		Hidden   int    `json:"hidden"`
+		Filtered int    `json:"filtered"`
+	}
+	// Every line with -ndjson is a record; a "dir" record is written before
+	// the "entry" records for that directory, and "error" records are written
+	// as soon as they happen.
+	jsonRecord struct {
+		Version int        `json:"version"`
+		Record  string     `json:"record"` // "dir", "entry", or "error".
+		Dir     *jsonDir   `json:"dir,omitempty"`
+		Entry   *jsonEntry `json:"entry,omitempty"`
+		Error   *jsonError `json:"error,omitempty"`
+	}
+	jsonError struct {
+		Path  string `json:"path"`  // Can be empty if the error isn't for a path.
+		Op    string `json:"op"`    // Operation, such as "lstat" or "open".
+		Errno *int   `json:"errno"` // System error number, if known.
+		Error string `json:"error"`
+	}
+	// Names that aren't valid UTF-8 have the invalid bytes replaced with
+	// U+FFFD in the string fields, and the original bytes are in the base64
+	// encoded *_bytes fields. These are omitted for valid UTF-8.
+	jsonEntry struct {
+		Name       string            `json:"name"`
+		NameBytes  []byte            `json:"name_bytes,omitempty"`
+		Path       string            `json:"path"`
+		PathBytes  []byte            `json:"path_bytes,omitempty"`
+		Type       string            `json:"type"`
+		Mode       string            `json:"mode"`
+		Permission fs.FileMode       `json:"permission"`
+		Hidden     bool              `json:"hidden"`
+		UID        *uint64           `json:"uid"`
+		User       *string           `json:"user"`
+		GID        *uint64           `json:"gid"`
+		Group      *string           `json:"group"`
+		Inode      uint64            `json:"inode"`
+		Links      uint64            `json:"links"`
+		Size       int64             `json:"size"`
+		Blocks     int64             `json:"blocks"`
+		Allocated  int64             `json:"allocated"`
+		DataSize   *int64            `json:"data_size,omitempty"`
+		ModTime    *time.Time        `json:"mod_time"`
+		BirthTime  *time.Time        `json:"birth_time"`
+		AccessTime *time.Time        `json:"access_time"`
+		LinkTarget *string           `json:"link_target"` // Only for symlinks.
+		LinkType   *string           `json:"link_type"`   // Only for symlinks; null if the target doesn't exist.
+		LinkBytes  []byte            `json:"link_target_bytes,omitempty"`
+		Xattrs     map[string]string `json:"xattrs,omitempty"`       // Text, or base64 with a "0s" prefix.
+		XattrBytes map[string][]byte `json:"xattrs_bytes,omitempty"` // Exact values.
+		Caps       string            `json:"capabilities,omitempty"`
+		Suspicious []string          `json:"suspicious,omitempty"` // Reasons the name may be deceptive.
+		Children   *struct {
+			Files int `json:"files"`
+			Dirs  int `json:"dirs"`
+		} `json:"children,omitempty"`
+	}
+)
+
+// Extended attributes are only included with xattrs (-@), and capabilities with
+// xattrs or caps, as they need extra syscalls for every entry.
+func printJSON(toPrint []printable, errs *errGroup, xattrs, caps bool) {
+	out := jsonOutput{
+		Version:  jsonVersion,
+		Errors:   make([]jsonError, 0, errs.Len()),
+		Listings: make([]jsonListing, 0, len(toPrint)),
+	}
+	for _, e := range errs.List() {
+		out.Errors = append(out.Errors, newJSONError(e))
+	}
+	for _, p := range toPrint {
+		out.Listings = append(out.Listings, newJSONListing(p, xattrs, caps))
+	}
+
+	j, err := json.MarshalIndent(out, "", "  ")
+	zli.F(err)
+	fmt.Fprintln(zli.Stdout, string(j))
+}
+
+// Write JSON records for -ndjson, one per line, as soon as they're available.
+type ndjsonWriter struct {
+	mu           sync.Mutex
+	w            io.Writer
+	xattrs, caps bool
+}
+
+func (w *ndjsonWriter) write(r jsonRecord) {
+	r.Version = jsonVersion
+	j, err := json.Marshal(r)
+	zli.F(err)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintln(w.w, string(j))
+}
+
+func (w *ndjsonWriter) error(err error) {
+	e := newJSONError(err)
+	w.write(jsonRecord{Record: "error", Error: &e})
+}
+
+func (w *ndjsonWriter) listing(p printable) {
+	d := newJSONDir(p)
+	w.write(jsonRecord{Record: "dir", Dir: &d})
+	for _, fi := range p.fi {
+		e := newJSONEntry(p, fi, w.xattrs, w.caps)
+		w.write(jsonRecord{Record: "entry", Dir: &d, Entry: &e})
+	}
+}
+
+func newJSONError(err error) jsonError {
+	e := jsonError{Error: err.Error()}
+	var pErr *fs.PathError
+	if errors.As(err, &pErr) {
+		e.Path, e.Op, e.Error = pErr.Path, pErr.Op, pErr.Err.Error()
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		n := int(errno)
+		e.Errno = &n
+	}
+	return e
+}
+
+func newJSONDir(p printable) jsonDir {
+	return jsonDir{
+		Dir:      p.dir,
+		AbsDir:   p.absdir,
+		IsFiles:  p.isFiles,
+		Hidden:   p.hidden,
+		Filtered: p.filtered,
+	}
+}
+
+func newJSONListing(p printable, xattrs, caps bool) jsonListing {
+	l := jsonListing{jsonDir: newJSONDir(p), Entries: make([]jsonEntry, 0, len(p.fi))}
+	for _, fi := range p.fi {
+		l.Entries = append(l.Entries, newJSONEntry(p, fi, xattrs, caps))
+	}
+	return l
+}
+
+func newJSONEntry(p printable, fi fileInfo, xattrs, caps bool) jsonEntry {
+	var (
+		absdir = p.absdir
+		path   = p.abs(fi)
+	)
+	if p.isFiles {
+		absdir = fi.filepathAbs
+	}
+	e := jsonEntry{
+		Name:       fi.Name(),
+		Path:       path,
+		Type:       fileType(fi.Mode(), fi),
+		Mode:       strmode(fi.Mode()),
+		Permission: fi.Mode().Perm(),
+		Hidden:     os2.Hidden(absdir, fs.FileInfoToDirEntry(fi)),
+		Inode:      os2.Serial(absdir, fi),
+		Links:      os2.Numlinks(absdir, fi),
+		Size:       fi.Size(),
+		Blocks:     fi.Blocks(),
+		Allocated:  max(fi.Blocks(), 0) * 512, // Blocks is -1 without stat data.
+		ModTime:    nullTime(fi.ModTime()),
+		BirthTime:  nullTime(os2.Btime(absdir, fi)),
+		AccessTime: nullTime(os2.Atime(fi)),
+		NameBytes:  invalidUTF8(fi.Name()),
+		PathBytes:  invalidUTF8(path),
+		Suspicious: deceptive(fi.Name()),
+	}
+
+	uid, gid := os2.OwnerID(absdir, fi)
+	if uid != "" {
+		u := userName(uid)
+		e.User = &u
+		if n, err := strconv.ParseUint(uid, 10, 64); err == nil {
+			e.UID = &n
+		}
+	}
+	if gid != "" {
+		g := groupName(gid)
+		e.Group = &g
+		if n, err := strconv.ParseUint(gid, 10, 64); err == nil {
+			e.GID = &n
+		}
+	}
+
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if t, err := os.Readlink(path); err == nil {
+			e.LinkTarget, e.LinkBytes = &t, invalidUTF8(t)
+		}
+		if st, err := os.Stat(path); err == nil {
+			t := fileType(st.Mode(), st)
+			e.LinkType = &t
+		}
+	}
+
+	// Only for sparse files, as it needs to open the file.
+	if isSparse(fi) {
+		if n, err := os2.DataSize(path); err == nil {
+			e.DataSize = &n
+		}
+	}
+	if !xattrs {
+		if caps {
+			e.Caps = capabilities(path)
+		}
+	} else if names, _ := os2.ListXattrs(path); len(names) > 0 {
+		e.Xattrs = make(map[string]string, len(names))
+		e.XattrBytes = make(map[string][]byte, len(names))
+		for _, n := range names {
+			v, _ := os2.GetXattr(path, n)
+			e.Xattrs[n], _ = fmtXattr(v)
+			e.XattrBytes[n] = v
+		}
+		if slices.Contains(names, xattrCap) {
+			e.Caps = capabilities(path)
+		}
+	}
+	if fi.count != nil {
+		e.Children = &struct {
+			Files int `json:"files"`
+			Dirs  int `json:"dirs"`
+		}{fi.count.files, fi.count.dirs}
+	}
+	return e
+}
+
+// Get the bytes of s if it's not valid UTF-8, or nil if it is.
+func invalidUTF8(s string) []byte {
+	if utf8.ValidString(s) {
+		return nil
+	}
+	return []byte(s)
+}
+
+// Times are null if they're not known, rather than "0001-01-01T00:00:00Z".
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Name of the file type, as used in the JSON output.
+func fileType(m fs.FileMode, fi fs.FileInfo) string {
+	switch {
+	case m.IsDir():
+		return "dir"
+	case m.IsRegular():
+		return "file"
+	case m&fs.ModeSymlink != 0:
+		return "symlink"
+	case m&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case m&fs.ModeSocket != 0:
+		return "socket"
+	case m&fs.ModeCharDevice != 0:
+		return "char_device"
+	case m&fs.ModeDevice != 0:
+		return "block_device"
+	case os2.IsDoor(fi):
+		return "door"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,332 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"zgo.at/elles/os2"
+)
+
+func setxattr(t *testing.T, path, name string, value []byte) {
+	t.Helper()
+	err := unix.Lsetxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		t.Skip("filesystem doesn't support xattrs")
+	}
+	if errors.Is(err, unix.EPERM) {
+		t.Skipf("no permission to set %s", name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXattr(t *testing.T) {
+	start(t)
+	now := time.Now().Format("15:04")
+	touch(t, "a")
+	touch(t, "b")
+	setxattr(t, "a", "user.comment", []byte("hello"))
+	setxattr(t, "a", "user.bin", []byte{0, 0xff, 1})
+
+	{
+		have := mustRun(t, "-llg", "-@")
+		have = strings.Join(strings.Fields(have), " ")
+		want := norm(`-rw-r--r--@ martin tournoij 0 Jan _2 15:04 │ a user.bin 3 user.comment 5 -rw-r--r-- martin tournoij 0 Jan _2 15:04 │ b`,
+			"Jan _2 15:04", time.Now().Format("Jan _2 15:04"))
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-l@@")
+		want := norm(`
+			     0 │ 15:04 │ a
+			    user.bin      3  0sAP8B
+			    user.comment  5  "hello"
+			     0 │ 15:04 │ b`, "15:04", now)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{ // Can't display the lines below entries in columns.
+		have := mustRun(t, "-l@@", "-C")
+		want := norm(`
+			     0 │ 15:04 │ a
+			    user.bin      3  0sAP8B
+			    user.comment  5  "hello"
+			     0 │ 15:04 │ b`, "15:04", now)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		if have := mustRun(t, "-j", "a"); strings.Contains(have, "xattrs") {
+			t.Error("xattrs without -@:", have)
+		}
+		have := mustRun(t, "-j", "-@", "a")
+		if !strings.Contains(have, `"user.comment": "hello"`) || !strings.Contains(have, `"user.bin": "0sAP8B"`) {
+			t.Error(have)
+		}
+		if !strings.Contains(have, `"user.comment": "aGVsbG8="`) || !strings.Contains(have, `"user.bin": "AP8B"`) {
+			t.Error(have)
+		}
+	}
+	{ // Text that looks like an encoded value.
+		touch(t, "enc")
+		setxattr(t, "enc", "user.enc", []byte("0sAP8B"))
+		have := mustRun(t, "-1@@", "-l", "enc")
+		if !strings.HasSuffix(have, "user.enc  6  0sMHNBUDhC") {
+			t.Error(have)
+		}
+		have = mustRun(t, "-j", "-@", "enc")
+		if !strings.Contains(have, `"user.enc": "0sMHNBUDhC"`) {
+			t.Error(have)
+		}
+	}
+}
+
+// Create the binary xattr representation of an ACL.
+func mkACL(acl os2.ACL) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, 2)
+	for _, e := range acl {
+		b = binary.LittleEndian.AppendUint16(b, uint16(e.Tag))
+		b = binary.LittleEndian.AppendUint16(b, uint16(e.Perm))
+		b = binary.LittleEndian.AppendUint32(b, e.ID)
+	}
+	return b
+}
+
+func TestACL(t *testing.T) {
+	start(t)
+	touch(t, "file")
+	touch(t, "plain")
+	mkdirAll(t, "dir")
+
+	acl := os2.ACL{
+		{Tag: os2.ACLUserObj, Perm: 6, ID: 0xffffffff},
+		{Tag: os2.ACLUser, Perm: 7, ID: 0},
+		{Tag: os2.ACLGroupObj, Perm: 4, ID: 0xffffffff},
+		{Tag: os2.ACLMask, Perm: 6, ID: 0xffffffff},
+		{Tag: os2.ACLOther, Perm: 4, ID: 0xffffffff},
+	}
+	setxattr(t, "file", "system.posix_acl_access", mkACL(acl))
+	setxattr(t, "dir", "system.posix_acl_default", mkACL(os2.ACL{
+		{Tag: os2.ACLUserObj, Perm: 7, ID: 0xffffffff},
+		{Tag: os2.ACLGroupObj, Perm: 5, ID: 0xffffffff},
+		{Tag: os2.ACLOther, Perm: 5, ID: 0xffffffff},
+	}))
+
+	access, _, err := os2.ACLs("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(access, acl) {
+		t.Errorf("\nhave: %v\nwant: %v", access, acl)
+	}
+
+	have := mustRun(t, "-ll", "-acl")
+	var modes []string
+	for _, l := range strings.Split(have, "\n") {
+		if !strings.HasPrefix(l, "    ") {
+			modes = append(modes, strings.Fields(l)[0])
+		}
+	}
+	if want := []string{"drwxr-xr-x+", "-rw-rw-r--+", "-rw-r--r--"}; !reflect.DeepEqual(modes, want) {
+		t.Errorf("\nhave: %v\nwant: %v", modes, want)
+	}
+	want := norm(`
+		    default:user::rwx
+		    default:group::r-x
+		    default:other::r-x
+		    user::rw-
+		    user:ROOT:rwx  #effective:rw-
+		    group::r--
+		    mask::rw-
+		    other::r--`, "ROOT", userName("0"))
+	var lines []string
+	for _, l := range strings.Split(have, "\n") {
+		if strings.HasPrefix(l, "    ") {
+			lines = append(lines, l)
+		}
+	}
+	if h := strings.Join(lines, "\n"); h != want {
+		t.Errorf("\nhave:\n%s\n\nwant:\n%s", h, want)
+	}
+}
+
+func TestSecContext(t *testing.T) {
+	start(t)
+	touch(t, "a")
+	touch(t, "b")
+	setxattr(t, "a", "security.selinux", []byte("system_u:object_r:tmp_t:s0\x00"))
+
+	have := mustRun(t, "-1Z")
+	want := "system_u:object_r:tmp_t:s0 a\n?                          b"
+	if have != want {
+		t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+	}
+
+	have = mustRun(t, "-ll")
+	if !strings.HasPrefix(have, "-rw-r--r--. ") {
+		t.Error(have)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	le := func(n ...uint32) []byte {
+		var b []byte
+		for _, nn := range n {
+			b = binary.LittleEndian.AppendUint32(b, nn)
+		}
+		return b
+	}
+
+	tests := []struct {
+		in      []byte
+		want    string
+		wantErr string
+	}{
+		{le(0x01000001, 1<<13, 0), "cap_net_raw=ep", ""},
+		{le(0x02000001, 1<<13|1<<12, 0, 0, 0), "cap_net_admin,cap_net_raw=ep", ""},
+		{le(0x02000000, 1, 1<<5, 1<<8, 0), "cap_chown,cap_checkpoint_restore=p cap_kill=i", ""},
+		{le(0x02000000, 0, 0, 1<<30, 0), "cap_62=p", ""},
+		{le(0x03000001, 1<<10, 0, 0, 0, 1000), "cap_net_bind_service=ep [rootid=1000]", ""},
+		{le(0x03000001, 1<<10, 0, 0, 0, 0), "cap_net_bind_service=ep", ""},
+
+		{le(0x02000001, 1<<13, 0), "", "wrong size for version 2"},
+		{le(0x04000001, 1<<13, 0), "", "unknown version 4"},
+		{[]byte{1}, "", "too short"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			c, err := os2.ParseCapabilities(tt.in)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("wrong error\nhave: %v\nwant: %v", err, tt.wantErr)
+			}
+			if have := c.String(); tt.wantErr == "" && have != tt.want {
+				t.Errorf("\nhave: %q\nwant: %q", have, tt.want)
+			}
+		})
+	}
+
+	t.Run("list", func(t *testing.T) {
+		start(t)
+		touch(t, "a")
+		touch(t, "b")
+		setxattr(t, "a", "security.capability", le(0x02000001, 1<<13, 0, 0, 0))
+
+		have := mustRun(t, "-1", "-cap")
+		want := "cap_net_raw=ep a\n               b"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	})
+}
+
+func TestFileFlags(t *testing.T) {
+	start(t)
+	touch(t, "a")
+	touch(t, "b")
+
+	fd, err := unix.Open("a", unix.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	fl, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		t.Skipf("FS_IOC_GETFLAGS not supported: %s", err)
+	}
+	err = unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(fl|0x40)) // FS_NODUMP_FL
+	if err != nil {
+		t.Skipf("FS_IOC_SETFLAGS not supported: %s", err)
+	}
+
+	have := mustRun(t, "-llg", "-flags")
+	var flags []string
+	for _, l := range strings.Split(have, "\n") {
+		flags = append(flags, strings.Fields(l)[3])
+	}
+	if len(flags) != 2 || len(flags[0]) != 22 || flags[0][6] != 'd' || flags[1][6] != '-' {
+		t.Errorf("wrong flags: %q\n%s", flags, have)
+	}
+}
+
+func TestMounts(t *testing.T) {
+	t.Run("mountinfo", func(t *testing.T) {
+		have, err := os2.ParseMountinfo(strings.NewReader(strings.Join([]string{
+			`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw`,
+			`36 22 0:32 / /mnt/with\040space ro,noexec master:1 - tmpfs tmpfs rw,size=1024k`,
+			`37 22 0:33 / /invalid rw`,
+		}, "\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []os2.Mount{
+			{ID: 22, Source: "/dev/sda1", Path: "/", Type: "ext4",
+				Options: []string{"rw", "relatime"}, SuperOptions: []string{"rw"}},
+			{ID: 36, Source: "tmpfs", Path: "/mnt/with space", Type: "tmpfs",
+				Options: []string{"ro", "noexec"}, SuperOptions: []string{"rw", "size=1024k"}},
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("\nhave: %#v\nwant: %#v", have, want)
+		}
+		if !have[1].ReadOnly() || !have[1].NoExec() || have[0].ReadOnly() {
+			t.Error("wrong options")
+		}
+	})
+	t.Run("mounts", func(t *testing.T) {
+		have, err := os2.ParseMounts(strings.NewReader(
+			"/dev/sda1 / ext4 rw,relatime 0 0\nproc /proc proc rw,nosuid 0 0\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []os2.Mount{
+			{Source: "/dev/sda1", Path: "/", Type: "ext4", Options: []string{"rw", "relatime"}},
+			{Source: "proc", Path: "/proc", Type: "proc", Options: []string{"rw", "nosuid"}},
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("\nhave: %#v\nwant: %#v", have, want)
+		}
+	})
+	t.Run("mount cmd", func(t *testing.T) {
+		have := os2.ParseMountCmd(strings.Join([]string{
+			`/dev/ada0p2 on / (ufs, local, soft-updates)`,
+			`/dev/disk3s1 on /Volumes/My Disk (apfs, local, read-only, noexec)`,
+			`/dev/sd0a on / type ffs (local, nodev)`,
+			``,
+		}, "\n"))
+		want := []os2.Mount{
+			{Source: "/dev/ada0p2", Path: "/", Type: "ufs", Options: []string{"local", "soft-updates"}},
+			{Source: "/dev/disk3s1", Path: "/Volumes/My Disk", Type: "apfs", Options: []string{"local", "ro", "noexec"}},
+			{Source: "/dev/sd0a", Path: "/", Type: "ffs", Options: []string{"local", "nodev"}},
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("\nhave: %#v\nwant: %#v", have, want)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		m, ok := os2.MountFor("/proc")
+		if !ok || m.Type != "proc" {
+			t.Skip("/proc not mounted")
+		}
+		if !os2.IsMountPoint("/proc") || os2.IsMountPoint("/proc/self") {
+			t.Error("IsMountPoint")
+		}
+
+		have := mustRun(t, "-1d", "-fstype", "-mounts", "/proc", "/proc/self/")
+		want := "proc proc /proc\nproc      /proc/self"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"zgo.at/elles/os2"
@@ -45,17 +46,52 @@ var (
 
 type (
 	printable struct {
-		dir     string // Belongs in dir; can be empty.
-		absdir  string
-		isFiles bool
-		fi      []fileInfo
+		dir      string // Belongs in dir; can be empty.
+		absdir   string
+		isFiles  bool
+		fi       []fileInfo
+		hidden   int // Number of hidden entries not in fi.
+		filtered int // Number of entries removed by -writable or -unwritable.
 	}
 	fileInfo struct {
 		fs.FileInfo
 		filepath, filepathAbs string
+		du                    *duSize        // Size of directory tree for -du.
+		count                 *dirCount      // Number of entries for -count.
+		hardlink              *hardlinkGroup // Set of hardlinks for -hardlinks.
+	}
+	dirCount      struct{ files, dirs int }
+	hardlinkGroup struct {
+		n, count  int
+		collapsed bool
 	}
 )
 
+// Absolute path to fi, which is an entry in p.
+func (p printable) abs(fi fileInfo) string {
+	if p.isFiles {
+		return filepath.Join(fi.filepathAbs, fi.Name())
+	}
+	return filepath.Join(p.absdir, fi.Name())
+}
+
+// Size in bytes, or the total size of the directory tree with -du.
+func (f fileInfo) Size() int64 {
+	if f.du != nil {
+		return f.du.apparent
+	}
+	return f.FileInfo.Size()
+}
+
+// Blocks is the number of allocated 512-byte blocks, or the total for the
+// directory tree with -du.
+func (f fileInfo) Blocks() int64 {
+	if f.du != nil {
+		return f.du.alloc / 512
+	}
+	return os2.Blocks(f.FileInfo)
+}
+
 func main() {
 	f := zli.NewFlags(os.Args)
 	var (
@@ -65,6 +101,7 @@ func main() {
 		completion   = f.String("", "completion")
 		all          = f.Bool(false, "a", "all", "A", "almost-all")
 		asJSON       = f.Bool(false, "j", "json")
+		ndjson       = f.Bool(false, "ndjson")
 		list         = f.IntCounter(0, "l")
 		prDir        = f.Bool(false, "d", "directory")
 		one          = f.Bool(!isTerm, "1")
@@ -96,6 +133,8 @@ func main() {
 		timeAccess   = f.Bool(false, "u")
 		comma        = f.Bool(false, ",")
 		quote        = f.IntCounter(0, "Q")
+		quoteStyle   = f.String("", "quoting-style")
+		quoteFor     = f.String("", "quote-for")
 		fullTime     = f.IntCounter(0, "T")
 		width        = f.Int(0, "w", "width")
 		trim         = f.Bool(false, "trim")
@@ -103,6 +142,35 @@ func main() {
 		octal        = f.Bool(false, "o", "octal")
 		group        = f.Bool(false, "g", "groupname")
 		minCols      = f.Int(0, "m", "min")
+		header       = f.Bool(false, "header")
+		summary      = f.Bool(false, "summary")
+		groupBy      = f.String("", "group")
+		du           = f.Bool(false, "du")
+		oneFS        = f.Bool(false, "x", "one-file-system")
+		count        = f.Bool(false, "count")
+		countSplit   = f.Bool(false, "count-split")
+		bars         = f.Bool(false, "bars")
+		barsTotal    = f.Bool(false, "bars-total")
+		si           = f.Bool(false, "si")
+		iec          = f.Bool(false, "iec")
+		digits       = f.String("", "digits")
+		xattr        = f.IntCounter(0, "@", "xattr")
+		context      = f.Bool(false, "Z", "context")
+		acl          = f.Bool(false, "acl")
+		caps         = f.Bool(false, "cap", "caps", "capabilities")
+		fileFlags    = f.Bool(false, "flags")
+		links        = f.Bool(false, "links", "nlink")
+		hardlinks    = f.IntCounter(0, "hardlinks")
+		access       = f.Bool(false, "access")
+		writable     = f.Bool(false, "writable")
+		unwritable   = f.Bool(false, "unwritable")
+		fstype       = f.Bool(false, "fstype")
+		mounts       = f.Bool(false, "mounts")
+		suspicious   = f.Bool(false, "suspicious")
+		dired        = f.Bool(false, "dired")
+		format       = f.String("", "format")
+		print0       = f.Bool(false, "0", "print0")
+		absPaths     = f.Bool(false, "abs", "absolute")
 	)
 	zli.F(f.Parse(zli.AllowMultiple()))
 	if colorBSD.Bool() && !color.Set() {
@@ -117,6 +185,31 @@ func main() {
 	default:
 		zli.Fatalf("invalid value for -color: %q", color)
 	}
+	var (
+		tpl    *template.Template
+		tplOpt opts // Set once all options are known.
+		isTpl  = strings.Contains(format.String(), "{{")
+	)
+	switch format.String() {
+	case "":
+	case "md":
+		*format.Pointer() = "markdown"
+		zli.WantColor = false
+	case "csv", "tsv", "html", "markdown":
+		zli.WantColor = false
+	default:
+		if !isTpl {
+			zli.Fatalf("invalid value for -format: %q", format)
+		}
+		var err error
+		tpl, err = parseTemplate(format.String(), &tplOpt)
+		if err != nil {
+			zli.Fatalf("invalid -format template: %s", err)
+		}
+	}
+	if dired.Bool() {
+		zli.WantColor = false
+	}
 	setColor()
 	if help.Bool() {
 		fmt.Fprint(zli.Stdout, usage)
@@ -140,12 +233,60 @@ func main() {
 		return
 	}
 
+	if dired.Bool() {
+		*list.Pointer() = 2
+		*trim.Pointer(), *header.Pointer(), *summary.Pointer() = false, false, false
+		*groupBy.Pointer(), *xattr.Pointer(), *acl.Pointer() = "", 0, false
+		*hyperlink.Pointer(), *bars.Pointer() = "never", false
+		// Exact sizes in bytes like ls -l, without digit grouping.
+		*blockSize.Pointer(), *sizeBlock.Pointer(), *comma.Pointer() = "1", false, false
+	}
 	if noTrim.Set() {
 		*trim.Pointer() = false
 	}
 	if sizeBlock.Bool() {
 		*blockSize.Pointer() = "s"
 	}
+	if si.Bool() && iec.Bool() {
+		zli.Fatalf("can't use -si and -iec together")
+	}
+	if suspicious.Bool() && zli.WantColor && colorSuspicious == "" {
+		colorSuspicious = (zli.White | zli.Red.Bg()).String()
+	}
+	if writable.Bool() && unwritable.Bool() {
+		zli.Fatalf("can't use -writable and -unwritable together")
+	}
+	if !quoteStyle.Set() && !quote.Set() {
+		// Same as GNU ls; invalid values are ignored.
+		if s := os.Getenv("QUOTING_STYLE"); slices.Contains(quotingStyles, s) {
+			*quoteStyle.Pointer() = s
+		}
+	}
+	if quoteStyle.String() != "" && !slices.Contains(quotingStyles, quoteStyle.String()) {
+		zli.Fatalf("invalid value for -quoting-style: %q", quoteStyle.String())
+	}
+	if !quoteFor.Set() {
+		*quoteFor.Pointer() = "bash"
+	} else if !slices.Contains(quotingShells, quoteFor.String()) {
+		zli.Fatalf("invalid value for -quote-for: %q", quoteFor.String())
+	} else if quoteStyle.String() == "" {
+		*quoteStyle.Pointer() = "shell-escape"
+	}
+	size, ok := parseBlockSize(blockSize.String(), si.Bool(), iec.Bool())
+	if !ok {
+		zli.Fatalf("invalid value for -B: %q", blockSize.String())
+	}
+	var numFmt *numFormat
+	if (comma.Bool() || digits.Set()) && !dired.Bool() {
+		nf := localeNumFormat(digits.String())
+		numFmt = &nf
+	}
+	if countSplit.Bool() {
+		*count.Pointer() = true
+	}
+	if barsTotal.Bool() {
+		*bars.Pointer() = true
+	}
 
 	doLink := false
 	switch strings.ToLower(hyperlink.String()) {
@@ -157,8 +298,21 @@ func main() {
 	default:
 		zli.Fatalf("invalid value for -hyperlink: %q", hyperlink)
 	}
+	switch format.String() {
+	case "csv", "tsv":
+		doLink, numFmt = false, nil
+		*bars.Pointer() = false
+	case "markdown":
+		doLink = false
+		*bars.Pointer() = false
+	case "html":
+		*bars.Pointer() = false
+	}
 
-	nostat := list.Int() == 0 && !classify.Bool() && !inode.Bool() && !asJSON.Bool()
+	nostat := list.Int() == 0 && !classify.Bool() && !inode.Bool() && !asJSON.Bool() && !ndjson.Bool() &&
+		!summary.Bool() && !du.Bool() && !bars.Bool() && !links.Bool() && hardlinks.Int() == 0 &&
+		!access.Bool() && !writable.Bool() && !unwritable.Bool() && colorMultiHardlink == "" && tpl == nil &&
+		format.String() != "html"
 	switch {
 	case sortNone.Bool():
 		*sortFlag.Pointer() = "none"
@@ -177,10 +331,17 @@ func main() {
 		*sortFlag.Pointer() = "width"
 	}
 	switch sortFlag.String() {
-	case "name", "none", "none-all", "size", "time", "version", "ext", "extension", "width":
+	case "name", "none", "none-all", "size", "time", "version", "ext", "extension", "width", "count":
 	default:
 		zli.Fatalf("invalid value for -sort: %q", sortFlag.String())
 	}
+	switch groupBy.String() {
+	case "", "type", "ext", "extension":
+	case "owner", "mdate", "date":
+		nostat = false
+	default:
+		zli.Fatalf("invalid value for -group: %q", groupBy.String())
+	}
 	timeField := "mtime"
 	if timeCreate.Bool() {
 		timeField = "btime"
@@ -194,24 +355,61 @@ func main() {
 
 	errs := &errGroup{MaxSize: 100}
 
+	var duw *duWalker
+	if du.Bool() {
+		duw = newDuWalker(errs, oneFS.Bool())
+	}
+	process := func(toPrint []printable) {
+		// Get directory sizes; needs to be done before ordering.
+		if duw != nil {
+			duw.fill(toPrint)
+		}
+
+		if writable.Bool() || unwritable.Bool() {
+			filterWritable(toPrint, writable.Bool())
+		}
+
+		if count.Bool() || sortFlag.String() == "count" {
+			countEntries(toPrint, errs, all.Bool())
+		}
+
+		// Order it.
+		order(toPrint, sortFlag.String(), timeField, sortReverse.Bool(), dirsFirst.Bool())
+
+		if hardlinks.Int() > 0 {
+			markHardlinks(toPrint, hardlinks.Int() > 1)
+		}
+	}
+
+	// Print every directory as soon as it's read.
+	if ndjson.Bool() {
+		w := &ndjsonWriter{w: zli.Stdout, xattrs: xattr.Int() > 0, caps: caps.Bool()}
+		errs.OnAppend = w.error
+		gather(f.Args, errs, all.Bool(), recurse.Bool(), prDir.Bool(),
+			derefCmdline.Bool(), derefAll.Bool(), nostat, func(p printable) {
+				pp := []printable{p}
+				process(pp)
+				w.listing(pp[0])
+			})
+		return
+	}
+
 	// Gather list to print.
 	toPrint := gather(f.Args, errs, all.Bool(), recurse.Bool(), prDir.Bool(),
-		derefCmdline.Bool(), derefAll.Bool(), nostat)
-
-	// Order it.
-	order(toPrint, sortFlag.String(), timeField, sortReverse.Bool(), dirsFirst.Bool())
+		derefCmdline.Bool(), derefAll.Bool(), nostat, nil)
+	process(toPrint)
 
 	// Print as JSON.
 	if asJSON.Bool() {
-		printJSON(toPrint, errs)
+		printJSON(toPrint, errs, xattr.Int() > 0, caps.Bool())
 		return
 	}
 
 	opt := opts{
-		blockSize:   blockSize.String(),
+		size:        size,
 		classify:    classify.Bool(),
 		cols:        cols.Bool(),
-		comma:       comma.Bool(),
+		digits:      numFmt,
 		dirSlash:    dirSlash.Bool(),
 		fullTime:    fullTime.Int(),
 		group:       group.Bool(),
@@ -221,13 +419,61 @@ func main() {
 		numericUID:  numericUID.Bool(),
 		octal:       octal.Bool(),
 		one:         one.Bool(),
-		quote:       quote.Int(),
+		quote:       quoting{level: quote.Int(), style: quoteStyle.String(), shell: quoteFor.String()},
 		recurse:     recurse.Bool(),
 		timeField:   timeField,
 		trim:        trim.Bool(),
 		maxColWidth: width.Int(),
 		derefAll:    derefAll.Bool(),
 		minCols:     minCols.Int(),
+		header:      header.Bool(),
+		summary:     summary.Bool(),
+		groupBy:     groupBy.String(),
+		count:       count.Bool(),
+		countSplit:  countSplit.Bool(),
+		bars:        bars.Bool(),
+		barsTotal:   barsTotal.Bool(),
+		xattr:       xattr.Int(),
+		context:     context.Bool(),
+		acl:         acl.Bool(),
+		caps:        caps.Bool(),
+		flags:       fileFlags.Bool(),
+		links:       links.Bool(),
+		hardlinks:   hardlinks.Int() > 0,
+		access:      access.Bool(),
+		fstype:      fstype.Bool(),
+		mounts:      mounts.Bool(),
+		suspicious:  suspicious.Bool(),
+		dired:       dired.Bool(),
+		raw:         format.String() == "csv" || format.String() == "tsv",
+	}
+
+	switch format.String() {
+	case "csv":
+		printCSV(toPrint, errs, opt, ',', recurse.Bool() || len(f.Args) > 1)
+		return
+	case "tsv":
+		printCSV(toPrint, errs, opt, '\t', recurse.Bool() || len(f.Args) > 1)
+		return
+	case "html":
+		printHTML(toPrint, errs, opt)
+		return
+	case "markdown":
+		printMarkdown(toPrint, errs, opt)
+		return
+	}
+	if dired.Bool() {
+		printDired(toPrint, errs, opt)
+		return
+	}
+	if print0.Bool() {
+		printNUL(toPrint, errs, absPaths.Bool())
+		return
+	}
+	if tpl != nil {
+		tplOpt = opt
+		printTemplate(toPrint, errs, tpl)
+		return
 	}
 
 	draw(toPrint, errs, opt, cols.Set())
@@ -244,128 +490,223 @@ func draw(toPrint []printable, errs *errGroup, opt opts, colsSet bool) {
 			fmt.Fprintln(zli.Stdout, filepath.ToSlash(filepath.Clean(p.dir))+":")
 		}
 
-		// Format for output in memory first. This makes alignment much easier
-		// because we may or may not add things such as "/". Even with very
-		// large directories it shouldn't take more than a few hundred K.
-		cc := getCols(p, opt)
-
-	refmt:
-		var (
-			fmtRows = make([]string, 0, len(cc.rows))
-			widths  = make([]int, 0, len(cc.rows))
-			longest int
-			buf     strings.Builder
-			w       int
-		)
-		for _, r := range cc.rows {
-			buf.Reset()
-			w = 0
-			for i, c := range r {
-				if i > 0 {
-					buf.WriteString(" ")
-					w++
+		if opt.bars {
+			opt.barScale = barScale(p, opt.barsTotal)
+		}
+		if opt.groupBy == "" {
+			drawGrid(p, opt, colsSet)
+		} else {
+			for j, s := range groupEntries(p, opt) {
+				if j > 0 {
+					fmt.Fprintln(zli.Stdout)
 				}
+				fmt.Fprintln(zli.Stdout, colorHeader+s.label+reset)
+				pp := p
+				pp.fi = s.fi
+				drawGrid(pp, opt, colsSet)
+			}
+		}
+
+		if opt.summary {
+			fmt.Fprintln(zli.Stdout, summarize(p, opt))
+		}
+	}
+
+	printErrors(errs)
+}
+
+// Print errors last, so they're more visible. ls does this at the top, and it's
+// easy to miss if pushed off the screen.
+func printErrors(errs *errGroup) {
+	for _, e := range errs.List() {
+		zli.Errorf(e)
+	}
+	if errs.Len() > 0 {
+		zli.Exit(1)
+	}
+}
+
+// Draw the entries in p as a grid.
+func drawGrid(p printable, opt opts, colsSet bool) {
+	// Format for output in memory first. This makes alignment much easier
+	// because we may or may not add things such as "/". Even with very
+	// large directories it shouldn't take more than a few hundred K.
+	cc := getCols(p, opt)
+
+	// The -@ and -acl lines below entries can only be printed with one entry
+	// per line.
+	if colsSet && slices.ContainsFunc(cc.below, func(l []string) bool { return len(l) > 0 }) {
+		colsSet = false
+	}
+
+refmt:
+	var (
+		fmtRows = make([]string, 0, len(cc.rows))
+		widths  = make([]int, 0, len(cc.rows))
+		longest int
+		buf     strings.Builder
+	)
+	fmtRow := func(r []col) (string, int) {
+		buf.Reset()
+		w, _, _ := formatRow(&buf, r, cc.longest, -1)
+		b := buf.String()
+		if opt.maxColWidth > 0 && w > opt.maxColWidth {
+			b = termtext.Slice(b, 0, opt.maxColWidth-1) + reset + "…"
+			w = opt.maxColWidth
+		}
+		return b, w
+	}
+	for _, r := range cc.rows {
+		b, w := fmtRow(r)
+		fmtRows, widths = append(fmtRows, b), append(widths, w)
+		if w > longest {
+			longest = w
+		}
+	}
+	var (
+		hdr  string
+		hdrW int
+	)
+	if len(cc.header) > 0 {
+		hdr, hdrW = fmtRow(cc.header)
+		hdr = colorHeader + hdr + reset
+		if hdrW > longest {
+			longest = hdrW
+		}
+	}
 
-				if c.prop&borderToLeft != 0 {
-					buf.WriteString("│ ")
-					w += 2
+one:
+	if (opt.one && !colsSet) || (opt.list > 0 && !colsSet) {
+		if hdr != "" {
+			if columns > 0 && opt.trim && hdrW > columns {
+				hdr = termtext.Slice(hdr, 0, columns-1) + reset + "…"
+			}
+			fmt.Fprintln(zli.Stdout, hdr)
+		}
+		for i, f := range fmtRows {
+			if columns > 0 && opt.trim && widths[i] > columns {
+				f = termtext.Slice(f, 0, columns-1) + reset + "…"
+			}
+			fmt.Fprintln(zli.Stdout, f)
+			if i < len(cc.below) {
+				for _, l := range cc.below[i] {
+					fmt.Fprintln(zli.Stdout, l)
 				}
-				if c.prop&alignNone != 0 {
-					w += c.w
-					buf.WriteString(c.s)
-				} else if c.prop&alignLeft != 0 {
-					pad := cc.longest[i] - c.w
-					buf.WriteString(c.s)
-					buf.WriteString(strings.Repeat(" ", pad))
-					w += c.w + pad
+			}
+		}
+	} else {
+		var (
+			colwidths []int
+			rows      [][]string
+			pad       = 2
+		)
+		if opt.list > 0 {
+			pad = 4
+		}
+		for i := range 200 {
+			r, w := recol(fmtRows, widths, i, pad)
+			for j := range w { // Make sure the header fits.
+				if j < len(w)-1 {
+					w[j] = max(w[j], hdrW+pad)
 				} else {
-					pad := cc.longest[i] - c.w
-					buf.WriteString(strings.Repeat(" ", pad))
-					buf.WriteString(c.s)
-					w += c.w + pad
+					w[j] = max(w[j], hdrW)
 				}
 			}
-
-			b := buf.String()
-			if opt.maxColWidth > 0 && w > opt.maxColWidth {
-				b = termtext.Slice(b, 0, opt.maxColWidth-1) + reset + "…"
-				w = opt.maxColWidth
+			if sum(w) > columns {
+				if i <= 1 {
+					rows, colwidths = r, w
+				}
+				break
 			}
-			fmtRows, widths = append(fmtRows, b), append(widths, w)
-			if w > longest {
-				longest = w
+			rows, colwidths = r, w
+		}
+		if opt.minCols > 0 && len(colwidths) < opt.minCols {
+			if opt.maxColWidth == 0 {
+				opt.maxColWidth = longest - 1
+			} else {
+				opt.maxColWidth--
 			}
+			goto refmt
 		}
 
-	one:
-		if (opt.one && !colsSet) || (opt.list > 0 && !colsSet) {
-			for i, f := range fmtRows {
-				if columns > 0 && opt.trim && widths[i] > columns {
-					f = termtext.Slice(f, 0, columns-1) + reset + "…"
-				}
-				fmt.Fprintln(zli.Stdout, f)
-			}
-		} else {
-			var (
-				colwidths []int
-				rows      [][]string
-				pad       = 2
-			)
-			if opt.list > 0 {
-				pad = 4
-			}
-			for i := range 200 {
-				r, w := recol(fmtRows, widths, i, pad)
-				if sum(w) > columns {
-					if i <= 1 {
-						rows, colwidths = r, w
-					}
+		// Only space for one column; restart as if -1 was set. Saves some
+		// special-fu here.
+		if len(colwidths) == 1 {
+			opt.one, colsSet = true, false
+			goto one
+		}
+
+		if hdr != "" && len(rows) > 0 {
+			for j := range rows[0] {
+				fmt.Fprint(zli.Stdout, hdr)
+				if j == len(rows[0])-1 {
 					break
 				}
-				rows, colwidths = r, w
-			}
-			if opt.minCols > 0 && len(colwidths) < opt.minCols {
-				if opt.maxColWidth == 0 {
-					opt.maxColWidth = longest - 1
+				if opt.list > 0 {
+					fmt.Fprint(zli.Stdout, strings.Repeat(" ", colwidths[j]-hdrW-2), "┃ ")
 				} else {
-					opt.maxColWidth--
+					fmt.Fprint(zli.Stdout, strings.Repeat(" ", colwidths[j]-hdrW))
 				}
-				goto refmt
-			}
-
-			// Only space for one column; restart as if -1 was set. Saves some
-			// special-fu here.
-			if len(colwidths) == 1 {
-				opt.one, colsSet = true, false
-				goto one
 			}
-
-			for i, r := range rows {
-				for j, c := range r {
-					x := i + len(rows)*j
-					if opt.list > 0 && j != len(r)-1 {
-						fmt.Fprint(zli.Stdout, c, strings.Repeat(" ", colwidths[j]-widths[x]-2))
-						fmt.Fprint(zli.Stdout, "┃ ")
-					} else {
-						fmt.Fprint(zli.Stdout, c)
-						if j != len(r)-1 {
-							fmt.Fprint(zli.Stdout, strings.Repeat(" ", colwidths[j]-widths[x]))
-						}
+			fmt.Fprintln(zli.Stdout)
+		}
+		for i, r := range rows {
+			for j, c := range r {
+				x := i + len(rows)*j
+				if opt.list > 0 && j != len(r)-1 {
+					fmt.Fprint(zli.Stdout, c, strings.Repeat(" ", colwidths[j]-widths[x]-2))
+					fmt.Fprint(zli.Stdout, "┃ ")
+				} else {
+					fmt.Fprint(zli.Stdout, c)
+					if j != len(r)-1 {
+						fmt.Fprint(zli.Stdout, strings.Repeat(" ", colwidths[j]-widths[x]))
 					}
 				}
-				fmt.Fprintln(zli.Stdout)
 			}
+			fmt.Fprintln(zli.Stdout)
 		}
 	}
+}
 
-	// Print errors last, so they're more visible. ls does this at the top, and
-	// it's easy to miss if pushed off the screen.
-	for _, e := range errs.List() {
-		zli.Errorf(e)
-	}
-	if errs.Len() > 0 {
-		zli.Exit(1)
+// Write the columns in r to buf, padded to the widths in longest. Empty cells
+// without alignment (e.g. no link target for -dired) are skipped, so there's no
+// trailing space. Returns the display width, and the byte offsets of the cell
+// at nameCol in buf (-1 if there is no such column).
+func formatRow(buf *strings.Builder, r []col, longest []int, nameCol int) (w, start, end int) {
+	start, end = -1, -1
+	for i, c := range r {
+		if c.s == "" && c.prop&alignNone != 0 {
+			continue
+		}
+		if i > 0 {
+			buf.WriteString(" ")
+			w++
+		}
+
+		if c.prop&borderToLeft != 0 {
+			buf.WriteString("│ ")
+			w += 2
+		}
+		pad := 0
+		if c.prop&alignNone == 0 {
+			pad = longest[i] - c.w
+		}
+		if c.prop&(alignNone|alignLeft) == 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		if i == nameCol {
+			start = buf.Len()
+		}
+		buf.WriteString(c.s)
+		if i == nameCol {
+			end = buf.Len()
+		}
+		if c.prop&alignLeft != 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		w += c.w + pad
 	}
+	return w, start, end
 }
 
 func recol(paths []string, pathWidths []int, ncols, pad int) ([][]string, []int) {
@@ -407,8 +748,10 @@ func sum(s []int) int {
 	return n
 }
 
-// Gather list of everything we want to print.
-func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefAll, nostat bool) []printable {
+// Gather all entries to print. If emit is set it's called for every directory
+// as soon as it's read rather than adding it to the returned list, and files
+// are emitted individually.
+func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefAll, nostat bool, emit func(printable)) []printable {
 	var (
 		toPrint    = make([]printable, 0, 16)
 		filesIndex = -1 // index in toPrint for individual files.
@@ -463,13 +806,14 @@ func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefA
 			var subdirs []string
 			for _, l := range ls {
 				if os2.Hidden(ad, l) && !all {
+					pr.hidden++
 					continue
 				}
 				//if !l.IsDir() && dirsOnly { continue }
 
 				// Don't call stat if we don't need to.
 				if nostat {
-					pr.fi = append(pr.fi, fileInfo{fakeFileInfo{l}, "", ""})
+					pr.fi = append(pr.fi, fileInfo{FileInfo: fakeFileInfo{l}})
 				} else {
 					var fi fs.FileInfo
 					if derefAll {
@@ -479,9 +823,9 @@ func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefA
 					}
 					if errs.Append(err) {
 						// Don't skip the entire file, just don't add stat info.
-						pr.fi = append(pr.fi, fileInfo{fakeFileInfo{l}, "", ""})
+						pr.fi = append(pr.fi, fileInfo{FileInfo: fakeFileInfo{l}})
 					} else {
-						pr.fi = append(pr.fi, fileInfo{fi, "", ""})
+						pr.fi = append(pr.fi, fileInfo{FileInfo: fi})
 					}
 				}
 
@@ -489,7 +833,11 @@ func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefA
 					subdirs = append(subdirs, filepath.Join(d, l.Name()))
 				}
 			}
-			toPrint = append(toPrint, pr)
+			if emit != nil {
+				emit(pr)
+			} else {
+				toPrint = append(toPrint, pr)
+			}
 			for _, s := range subdirs {
 				addArg(s)
 			}
@@ -501,16 +849,23 @@ func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefA
 			ad, err := filepath.Abs(d)
 			errs.Append(err)
 
-			if filesIndex == -1 {
+			if emit != nil {
+				emit(printable{
+					dir:     d,
+					absdir:  ad,
+					isFiles: true,
+					fi:      []fileInfo{{FileInfo: fi, filepath: d, filepathAbs: ad}},
+				})
+			} else if filesIndex == -1 {
 				toPrint = append(toPrint, printable{
 					dir:     d,
 					absdir:  ad,
 					isFiles: true,
-					fi:      []fileInfo{{fi, d, ad}},
+					fi:      []fileInfo{{FileInfo: fi, filepath: d, filepathAbs: ad}},
 				})
 				filesIndex = len(toPrint) - 1
 			} else {
-				toPrint[filesIndex].fi = append(toPrint[filesIndex].fi, fileInfo{fi, d, ad})
+				toPrint[filesIndex].fi = append(toPrint[filesIndex].fi, fileInfo{FileInfo: fi, filepath: d, filepathAbs: ad})
 			}
 		}
 	}
@@ -531,6 +886,89 @@ func gather(args []string, errs *errGroup, all, recurse, prDir, derefCmd, derefA
 	return toPrint
 }
 
+// Count the number of entries in all directories in toPrint. This doesn't stat
+// the entries.
+func countEntries(toPrint []printable, errs *errGroup, all bool) {
+	for _, p := range toPrint {
+		for i, fi := range p.fi {
+			if !fi.IsDir() {
+				continue
+			}
+			path := p.abs(fi)
+			ls, err := os2.ReadDir(path)
+			if errs.Append(err) {
+				continue
+			}
+			var c dirCount
+			for _, l := range ls {
+				if !all && os2.Hidden(path, l) {
+					continue
+				}
+				if l.IsDir() {
+					c.dirs++
+				} else {
+					c.files++
+				}
+			}
+			p.fi[i].count = &c
+		}
+	}
+}
+
+// Remove entries that the current user can't write to for -writable, or that
+// they can write to for -unwritable. This is done after gathering, so with -R
+// directories that are removed are still listed.
+func filterWritable(toPrint []printable, want bool) {
+	for i := range toPrint {
+		keep := toPrint[i].fi[:0]
+		for _, fi := range toPrint[i].fi {
+			if _, w, _ := os2.Access(toPrint[i].abs(fi), fi); w != want {
+				toPrint[i].filtered++
+				continue
+			}
+			keep = append(keep, fi)
+		}
+		toPrint[i].fi = keep
+	}
+}
+
+// Find entries that link to the same file for -hardlinks. Every set of
+// hardlinks gets a number in the order they're listed, and with collapse only
+// the first entry is kept.
+func markHardlinks(toPrint []printable, collapse bool) {
+	var (
+		groups = make(map[[2]uint64]*hardlinkGroup)
+		n      int
+	)
+	for _, p := range toPrint {
+		for _, fi := range p.fi {
+			if k, ok := os2.Hardlink(fi); ok {
+				if groups[k] == nil {
+					groups[k] = &hardlinkGroup{collapsed: collapse}
+				}
+				groups[k].count++
+			}
+		}
+	}
+	for i := range toPrint {
+		keep := toPrint[i].fi[:0]
+		for _, fi := range toPrint[i].fi {
+			if k, ok := os2.Hardlink(fi); ok && groups[k].count > 1 {
+				g := groups[k]
+				if g.n == 0 {
+					n++
+					g.n = n
+				} else if collapse {
+					continue
+				}
+				fi.hardlink = g
+			}
+			keep = append(keep, fi)
+		}
+		toPrint[i].fi = keep
+	}
+}
+
 //func getEnv(name string) (string, bool) {
 //	l, ok := os.LookupEnv(name)
 //	if !ok {
@@ -591,6 +1029,16 @@ func order(toPrint []printable, sortby, timeField string, reverse, dirsFirst boo
 		sorter = func(a, b fileInfo) int { return cmp.Compare(filepath.Ext(a.Name()), filepath.Ext(b.Name())) }
 	case "version":
 		sorter = func(a, b fileInfo) int { return versCompare(a.Name(), b.Name()) }
+	case "count":
+		sorter = func(a, b fileInfo) int {
+			n := func(fi fileInfo) int {
+				if fi.count == nil {
+					return -1
+				}
+				return fi.count.files + fi.count.dirs
+			}
+			return cmp.Compare(n(b), n(a))
+		}
 	case "width":
 		// TODO: maybe make it sort by display width (with quotes and all of
 		// that)? That's what GNU ls does.
@@ -647,6 +1095,113 @@ func order(toPrint []printable, sortby, timeField string, reverse, dirsFirst boo
 	})
 }
 
+type section struct {
+	label string
+	fi    []fileInfo
+}
+
+// Descriptions for -group=ext; everything else is displayed as "*.ext".
+var extLabels = map[string]string{
+	".go": "Go sources", ".c": "C sources", ".h": "C headers", ".py": "Python sources",
+	".rs": "Rust sources", ".js": "JavaScript sources", ".sh": "Shell scripts",
+	".md": "Markdown documents", ".txt": "Text files", ".json": "JSON files",
+	".html": "HTML documents", ".css": "CSS stylesheets", ".pdf": "PDF documents",
+	".png": "PNG images", ".jpg": "JPEG images", ".jpeg": "JPEG images", ".gif": "GIF images",
+	".mp3": "MP3 audio", ".mkv": "Matroska videos", ".mp4": "MP4 videos",
+	".tar": "Tar archives", ".gz": "Gzip archives", ".zip": "Zip archives",
+	".o": "Object files", ".log": "Log files",
+}
+
+// Split the entries in p in labelled sections for -group. The entries keep the
+// order they have in p.
+func groupEntries(p printable, opt opts) []section {
+	var (
+		keys   = make([]string, 0, 8)
+		groups = make(map[string][]fileInfo)
+		label  func(fileInfo) (key, label string)
+		labels = make(map[string]string)
+	)
+	switch opt.groupBy {
+	case "type":
+		label = func(fi fileInfo) (string, string) {
+			m := fi.Mode()
+			switch {
+			case m.IsDir():
+				return "0", "Directories"
+			case m.IsRegular():
+				return "1", "Files"
+			case m&fs.ModeSymlink != 0:
+				return "2", "Symlinks"
+			case m&fs.ModeNamedPipe != 0, m&fs.ModeSocket != 0, os2.IsDoor(fi):
+				return "3", "Pipes and sockets"
+			case m&fs.ModeDevice != 0:
+				return "4", "Devices"
+			default:
+				return "5", "Other"
+			}
+		}
+	case "ext", "extension":
+		label = func(fi fileInfo) (string, string) {
+			ext := strings.ToLower(filepath.Ext(fi.Name()))
+			if ext == "" || ext == fi.Name() {
+				return "", "No extension"
+			}
+			if l, ok := extLabels[ext]; ok {
+				return ext, l
+			}
+			return ext, "*" + ext
+		}
+	case "owner":
+		label = func(fi fileInfo) (string, string) {
+			u, _ := owner(p.absdir, fi, opt.numericUID)
+			return u, "Owned by " + u
+		}
+	case "mdate", "date":
+		var (
+			verb  = timeHeader(opt.timeField)
+			now   = time.Now()
+			today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		)
+		label = func(fi fileInfo) (string, string) {
+			t := getTime(p.absdir, fi, opt.timeField)
+			switch {
+			case t.IsZero():
+				return "7", "Unknown"
+			case t.After(now):
+				return "0", verb + " in the future"
+			case !t.Before(today):
+				return "1", verb + " today"
+			case !t.Before(today.AddDate(0, 0, -1)):
+				return "2", verb + " yesterday"
+			case !t.Before(today.AddDate(0, 0, -7)):
+				return "3", verb + " this week"
+			case !t.Before(today.AddDate(0, -1, 0)):
+				return "4", verb + " this month"
+			case !t.Before(today.AddDate(-1, 0, 0)):
+				return "5", verb + " this year"
+			default:
+				return "6", verb + " more than a year ago"
+			}
+		}
+	}
+
+	for _, fi := range p.fi {
+		k, l := label(fi)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+			labels[k] = l
+		}
+		groups[k] = append(groups[k], fi)
+	}
+	slices.Sort(keys)
+
+	s := make([]section, 0, len(keys))
+	for _, k := range keys {
+		s = append(s, section{label: labels[k], fi: groups[k]})
+	}
+	return s
+}
+
 // cmp(a, b) should return a negative number when a < b, a positive number when
 // a > b and zero when a == b.
 func versCompare(a, b string) int {
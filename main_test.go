@@ -3,14 +3,17 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"net"
 	"os"
 	"os/user"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,10 +46,13 @@ func TestGroupDigits(t *testing.T) {
 
 		{"102G", "102G"},
 		{"1024G", "1,024G"},
+		{"1000kB", "1,000kB"},
+		{"1023KiB", "1,023KiB"},
+		{"1.5KiB", "1.5KiB"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.in, func(t *testing.T) {
-			have := groupDigits(tt.in)
+			have := groupDigits(tt.in, numFormat{",", ".", false})
 			if have != tt.want {
 				t.Errorf("\nhave: %q\nwant: %q", have, tt.want)
 			}
@@ -54,18 +60,63 @@ func TestGroupDigits(t *testing.T) {
 	}
 }
 
+func TestGroupDigitsLocale(t *testing.T) {
+	tests := []struct {
+		locale, in, want string
+	}{
+		{"", "1234567.5", "1,234,567.5"},
+		{"C", "1234567.5", "1,234,567.5"},
+		{"en_US.UTF-8", "1234567.5", "1,234,567.5"},
+		{"de_DE.UTF-8", "1234567.5", "1.234.567,5"},
+		{"de_DE@euro", "1234.5K", "1.234,5K"},
+		{"de_CH", "1234567.5", "1'234'567.5"},
+		{"fr_FR.UTF-8", "1234567", "1 234 567"},
+		{"fr-CA", "1234567", "1 234 567"},
+		{"hi_IN", "1234567", "12,34,567"},
+		{"en_IN", "123456789.5M", "12,34,56,789.5M"},
+		{"en_IN", "123", "123"},
+		{"en_IN", "1234", "1,234"},
+		{"de_DE", "123.5", "123,5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+" "+tt.in, func(t *testing.T) {
+			have := groupDigits(tt.in, localeNumFormat(tt.locale))
+			if have != tt.want {
+				t.Errorf("\nhave: %q\nwant: %q", have, tt.want)
+			}
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		start(t)
+		echoTrunc(t, strings.Repeat("x", 1234567), "file")
+
+		t.Setenv("LC_NUMERIC", "de_DE.UTF-8")
+		if have := mustRun(t, "-1,", "-B1", "-summary"); !strings.Contains(have, "1.234.567 apparent") {
+			t.Error(have)
+		}
+		t.Setenv("LC_ALL", "hi_IN.UTF-8")
+		if have := mustRun(t, "-l,", "-B1"); !strings.HasPrefix(have, " 12,34,567 │") {
+			t.Error(have)
+		}
+		if have := mustRun(t, "-l", "-B1", "-digits=fr_FR"); !strings.HasPrefix(have, " 1 234 567 │") {
+			t.Error(have)
+		}
+	})
+}
+
 func BenchmarkGroupDigits(b *testing.B) {
 	var g any
 	b.Run("no suffix", func(b *testing.B) {
 		b.ReportAllocs()
 		for n := 0; n < b.N; n++ {
-			g = groupDigits("12345678.10")
+			g = groupDigits("12345678.10", numFormat{",", ".", false})
 		}
 	})
 	b.Run("with suffix", func(b *testing.B) {
 		b.ReportAllocs()
 		for n := 0; n < b.N; n++ {
-			g = groupDigits("12345678K")
+			g = groupDigits("12345678K", numFormat{",", ".", false})
 		}
 	})
 	_ = g
@@ -78,48 +129,116 @@ func TestJSON(t *testing.T) {
 
 	start(t)
 	touch(t, "file1")
-	touch(t, "file2")
+	symlink(t, "file1", "link")
+	symlink(t, "nonexistent", "broken")
 
-	var have, want []map[string]any
-	err := json.Unmarshal([]byte(mustRun(t, "-j")), &have)
+	var have, want map[string]any
+	out, _ := run(t, "-j", ".", "nonexistent")
+	err := json.Unmarshal([]byte(out), &have)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = json.Unmarshal([]byte(`
-		[{
-		  "abs_dir": "/tmp/TestJSON3123184094/001",
-		  "dir":     ".",
-		  "entries": [
-		    {
-		      "access_time": "2024-06-10T01:39:35.284680724+01:00",
-		      "birth_time":  "2024-06-10T01:39:35.284680724+01:00",
-		      "mod_time":    "2024-06-10T01:39:35.284680724+01:00",
-		      "name":        "file1",
-		      "permission":  420,
-		      "size":        0,
-		      "type":        0
-		    },
-		    {
-		      "access_time": "2024-06-10T01:39:35.284680724+01:00",
-		      "birth_time":  "2024-06-10T01:39:35.284680724+01:00",
-		      "mod_time":    "2024-06-10T01:39:35.284680724+01:00",
-		      "name":        "file2",
-		      "permission":  420,
-		      "size":        0,
-		      "type":        0
-		    }
-		  ]
-		}]`), &want)
+	err = json.Unmarshal([]byte(norm(`
+		{
+		  "version": 1,
+		  "errors": [{
+		    "path":  "nonexistent",
+		    "op":    "lstat",
+		    "errno": 2,
+		    "error": "no such file or directory"
+		  }],
+		  "listings": [{
+		    "abs_dir":  "/tmp/TestJSON3123184094/001",
+		    "dir":      ".",
+		    "is_files": false,
+		    "hidden":   0,
+		    "filtered": 0,
+		    "entries": [
+		      {
+		        "name":        "broken",
+		        "path":        "/tmp/TestJSON3123184094/001/broken",
+		        "type":        "symlink",
+		        "mode":        "lrwxrwxrwx",
+		        "permission":  511,
+		        "hidden":      false,
+		        "uid":         1000,
+		        "user":        "martin",
+		        "gid":         1000,
+		        "group":       "tournoij",
+		        "inode":       1,
+		        "links":       1,
+		        "size":        11,
+		        "blocks":      0,
+		        "allocated":   0,
+		        "access_time": "2024-06-10T01:39:35.284680724+01:00",
+		        "birth_time":  "2024-06-10T01:39:35.284680724+01:00",
+		        "mod_time":    "2024-06-10T01:39:35.284680724+01:00",
+		        "link_target": "nonexistent",
+		        "link_type":   null
+		      },
+		      {
+		        "name":        "file1",
+		        "path":        "/tmp/TestJSON3123184094/001/file1",
+		        "type":        "file",
+		        "mode":        "-rw-r--r--",
+		        "permission":  420,
+		        "hidden":      false,
+		        "uid":         1000,
+		        "user":        "martin",
+		        "gid":         1000,
+		        "group":       "tournoij",
+		        "inode":       1,
+		        "links":       1,
+		        "size":        0,
+		        "blocks":      0,
+		        "allocated":   0,
+		        "access_time": "2024-06-10T01:39:35.284680724+01:00",
+		        "birth_time":  "2024-06-10T01:39:35.284680724+01:00",
+		        "mod_time":    "2024-06-10T01:39:35.284680724+01:00",
+		        "link_target": null,
+		        "link_type":   null
+		      },
+		      {
+		        "name":        "link",
+		        "path":        "/tmp/TestJSON3123184094/001/link",
+		        "type":        "symlink",
+		        "mode":        "lrwxrwxrwx",
+		        "permission":  511,
+		        "hidden":      false,
+		        "uid":         1000,
+		        "user":        "martin",
+		        "gid":         1000,
+		        "group":       "tournoij",
+		        "inode":       1,
+		        "links":       1,
+		        "size":        5,
+		        "blocks":      0,
+		        "allocated":   0,
+		        "access_time": "2024-06-10T01:39:35.284680724+01:00",
+		        "birth_time":  "2024-06-10T01:39:35.284680724+01:00",
+		        "mod_time":    "2024-06-10T01:39:35.284680724+01:00",
+		        "link_target": "file1",
+		        "link_type":   "file"
+		      }
+		    ]
+		  }]
+		}`)), &want)
 	if err != nil {
 		t.Fatal(err)
 	}
-	for i := range have {
-		have[i]["abs_dir"] = want[i]["abs_dir"]
-		for j := range have[i]["entries"].([]any) {
-			m := have[i]["entries"].([]any)[j].(map[string]any)
-			m["access_time"] = want[i]["entries"].([]any)[j].(map[string]any)["access_time"]
-			m["birth_time"] = want[i]["entries"].([]any)[j].(map[string]any)["birth_time"]
-			m["mod_time"] = want[i]["entries"].([]any)[j].(map[string]any)["mod_time"]
+
+	// Copy values that differ per system or run.
+	for i := range have["listings"].([]any) {
+		hl, wl := have["listings"].([]any)[i].(map[string]any), want["listings"].([]any)[i].(map[string]any)
+		wl["abs_dir"] = hl["abs_dir"]
+		for j := range hl["entries"].([]any) {
+			h, w := hl["entries"].([]any)[j].(map[string]any), wl["entries"].([]any)[j].(map[string]any)
+			for _, k := range []string{"path", "uid", "gid", "inode", "access_time", "birth_time", "mod_time"} {
+				w[k] = h[k]
+			}
+			if !strings.HasSuffix(h["path"].(string), "/"+h["name"].(string)) {
+				t.Errorf("wrong path: %s", h["path"])
+			}
 		}
 	}
 	if !reflect.DeepEqual(have, want) {
@@ -129,6 +248,367 @@ func TestJSON(t *testing.T) {
 	}
 }
 
+func TestNDJSON(t *testing.T) {
+	start(t)
+	mkdirAll(t, "dir/sub")
+	touch(t, "dir/file")
+	touch(t, "dir/sub/file")
+
+	out, _ := run(t, "-ndjson", "-R", "dir", "nonexistent", "dir/file")
+	var have []string
+	for _, l := range strings.Split(out, "\n") {
+		var r struct {
+			Version int    `json:"version"`
+			Record  string `json:"record"`
+			Dir     struct {
+				Dir string `json:"dir"`
+			} `json:"dir"`
+			Entry struct {
+				Name string `json:"name"`
+			} `json:"entry"`
+			Error struct {
+				Path string `json:"path"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("%s: %q", err, l)
+		}
+		if r.Version != 1 {
+			t.Errorf("version %d", r.Version)
+		}
+		switch r.Record {
+		case "dir":
+			have = append(have, "dir "+filepath.ToSlash(r.Dir.Dir))
+		case "entry":
+			have = append(have, "entry "+r.Entry.Name)
+		case "error":
+			have = append(have, "error "+r.Error.Path)
+		}
+	}
+
+	want := []string{
+		"dir ./dir", "entry file", "entry sub",
+		"dir ./dir/sub", "entry file",
+		"error nonexistent",
+		"dir dir/", "entry file",
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Utimes, symlinks.
+	}
+	start(t)
+
+	tt := time.Date(2024, 6, 10, 13, 14, 15, 0, time.UTC)
+	touchDate(t, tt, "a,b")
+	touchDate(t, tt, `quote"tab	`)
+	mkdirAll(t, "dir")
+	touchDate(t, tt, "dir", "file")
+	ts := tt.Local().Format(time.RFC3339)
+
+	{
+		have := mustRun(t, "-format=csv", "-l", "-color=always", "a,b", `quote"tab	`)
+		want := "Directory,Size,Modified,Name,Target\n" +
+			",0," + ts + ",\"a,b\",\n" +
+			",0," + ts + ",\"quote\"\"tab\t\","
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		symlink(t, "a,b", "dir", "link")
+		have := mustRun(t, "-format=tsv", "-ll", "-,", "dir")
+		want := norm(`
+			Mode|User|Group|Size|Modified|Name|Target
+			-rw-r--r--|martin|tournoij|0|` + ts + `|file|
+			lrwxrwxrwx|martin|tournoij|3|TIME|link|a,b`)
+		want = strings.ReplaceAll(want, "|", "\t")
+		have = regexp.MustCompile(`\t3\t[^\t]+\t`).ReplaceAllString(have, "\t3\tTIME\t")
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-format=csv", "-i", "dir", "a,b")
+		if !strings.HasPrefix(have, "Directory,Inode,Name\n") || !strings.Contains(have, `,"a,b"`) {
+			t.Errorf("\nhave:\n%s", have)
+		}
+	}
+	{ // Same columns with -R, whether or not there are subdirectories.
+		have := mustRun(t, "-format=csv", "-R", "dir")
+		if !strings.HasPrefix(have, "Directory,Name\n./dir,file\n./dir,link") {
+			t.Errorf("\nhave:\n%s", have)
+		}
+	}
+	{
+		have, ok := run(t, "-format=xxx")
+		if ok || !strings.Contains(have, `invalid value for -format: "xxx"`) {
+			t.Errorf("wrong output: %q", have)
+		}
+	}
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Utimes.
+	}
+	start(t)
+
+	tt := time.Date(2024, 6, 10, 13, 14, 15, 0, time.UTC)
+	touchDate(t, tt, "a|b")
+	touchDate(t, tt, "*star*")
+	mkdirAll(t, "dir")
+	touchDate(t, tt, "dir", "file")
+	echoTrunc(t, strings.Repeat("x", 2048), "dir", "file")
+
+	{
+		have := mustRun(t, "-format=markdown", "-l", "-T", "-color=always", "a|b", "*star*")
+		want := norm(`
+			| Size | Modified | Name |
+			| ---: | ---: | --- |
+			| 0 | ` + tt.Local().Format("2006-01-02 15:04:05") + ` | \*star\* |
+			| 0 | ` + tt.Local().Format("2006-01-02 15:04:05") + ` | a\|b |`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-format=md", "-ll", "dir")
+		want := norm(`
+			| Mode | User | Group | Size | Modified | Name |
+			| --- | --- | --- | ---: | ---: | --- |
+			| -rw-r--r-- | martin | tournoij | 2.0K | TIME | file |`)
+		have = regexp.MustCompile(`2.0K \| [^|]+ \|`).ReplaceAllString(have, "2.0K | TIME |")
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-format=markdown", "-i", ".", "dir")
+		if !strings.HasPrefix(have, "## .\n\n| Inode | Name |\n| ---: | --- |\n") || !strings.Contains(have, "\n\n## dir\n\n") {
+			t.Errorf("\nhave:\n%s", have)
+		}
+	}
+}
+
+func TestFormatHTML(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Symlinks.
+	}
+	start(t)
+	touch(t, "<file>")
+	mkdirAll(t, "dir")
+	symlink(t, "dir", "link")
+	symlink(t, "nonexistent", "broken")
+
+	body := func(s string) string {
+		s = s[strings.Index(s, "<body>\n")+7:]
+		return s[:strings.Index(s, "</body>")]
+	}
+
+	{
+		have := body(mustRun(t, "-format=html", "-color=always"))
+		want := norm(`
+			<ul class="elles">
+			<li><span class="fi">&lt;file&gt;</span></li>
+			<li><span class="or">broken</span></li>
+			<li><span class="di">dir</span></li>
+			<li><span class="ln">link</span></li>
+			</ul>`) + "\n"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := body(mustRun(t, "-format=html", "-l", "-F", "-hyperlink=always", "link", "<file>"))
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"<thead><tr><th>Size</th><th>Modified</th><th>Name</th></tr></thead>",
+			`<td class="num">0</td>`,
+			`<a class="fi" href="file://`,
+			`/%3Cfile%3E">&lt;file&gt;</a>`,
+			`<a class="ln" href="file://`,
+			filepath.ToSlash(wd) + `/link">link → dir/</a>`,
+		} {
+			if !strings.Contains(have, want) {
+				t.Errorf("%q not in output:\n%s", want, have)
+			}
+		}
+	}
+
+	// Every class fileClass can add needs a style.
+	for _, c := range []string{"su", "sg", "ca", "ex", "mh", "fi", "tw", "st", "ow", "di",
+		"pi", "so", "bd", "cd", "do", "ln", "or", "hidden", "sparse", "suspicious"} {
+		if !regexp.MustCompile(`\.` + c + `[ ,]`).MatchString(htmlStyle) {
+			t.Errorf("no style for %q", c)
+		}
+	}
+}
+
+func TestDired(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Symlinks, mode.
+	}
+	start(t)
+
+	tt := time.Date(2024, 6, 10, 13, 14, 15, 0, time.Local)
+	echoTrunc(t, strings.Repeat("x", 5000), "file")
+	if err := os2.Utimes("file", tt, tt); err != nil {
+		t.Fatal(err)
+	}
+	touchDate(t, tt, "with space")
+	symlink(t, "file", "link")
+	chmod(t, 0o644, "file")
+	chmod(t, 0o644, "with space")
+
+	have := mustRun(t, "-dired", "-l", "-color=always", "-,", "file", "with space", "link")
+	want := norm(`
+		  -rw-r--r-- 1 martin tournoij 5000 Jun 10  2024 file
+		  lrwxrwxrwx 1 martin tournoij    4 Jun 10  2024 link -> file
+		  -rw-r--r-- 1 martin tournoij    0 Jun 10  2024 with space
+		//DIRED// OFFSETS
+		//DIRED-OPTIONS// --quoting-style=literal`)
+
+	// Time for the symlink isn't set, so replace it.
+	have = regexp.MustCompile(`4 \w+ [ \d]\d [ \d:]{5} link`).ReplaceAllString(have, "4 Jun 10  2024 link")
+	lines := strings.Split(have, "\n")
+	lines[len(lines)-2] = "//DIRED// OFFSETS"
+	if h := strings.Join(lines, "\n"); h != want {
+		t.Errorf("\nhave:\n%s\n\nwant:\n%s", h, want)
+	}
+
+	// Offsets are from the real output.
+	have = mustRun(t, "-dired", "-l", "file", "with space", "link")
+	off := strings.Fields(strings.Split(have, "\n")[3])[1:]
+	var names []string
+	for i := 0; i < len(off); i += 2 {
+		s, _ := strconv.Atoi(off[i])
+		e, _ := strconv.Atoi(off[i+1])
+		names = append(names, have[s:e])
+	}
+	if h := strings.Join(names, "|"); h != "file|link|with space" {
+		t.Errorf("wrong names: %q", h)
+	}
+
+	mkdirAll(t, "dir")
+	have = mustRun(t, "-dired", ".", "dir")
+	if !strings.HasPrefix(have, "  .:\n  total ") || !strings.Contains(have, "\n\n  dir:\n  total 0\n") {
+		t.Errorf("\nhave:\n%s", have)
+	}
+	if !strings.Contains(have, "\n//SUBDIRED// 2 3 ") {
+		t.Errorf("\nhave:\n%s", have)
+	}
+}
+
+func TestFormatTemplate(t *testing.T) {
+	start(t)
+	echoTrunc(t, strings.Repeat("x", 2048), "file")
+	mkdirAll(t, "dir")
+
+	{
+		have := mustRun(t, "-format={{.Name}}\\t{{.Size | human}}\\t{{.Type}}\\t{{.ModTime | since}}", "-B1", "file")
+		want := "file\t2048\tfile\tjust now"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+	{
+		have := mustRun(t, `-format={{quote .Name}} {{.Hidden}} {{printf "%d" .Links}}`, "file")
+		want := "file false 1"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-F", "-format={{color .}}")
+		want := "dir/\nfile"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+	{
+		have, ok := run(t, "-format={{.Name")
+		if ok || !strings.Contains(have, "invalid -format template") {
+			t.Errorf("wrong output: %q", have)
+		}
+	}
+}
+
+func TestSince(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{5 * time.Minute, "5 minutes ago"},
+		{time.Hour + time.Minute, "1 hour ago"},
+		{50 * time.Hour, "2 days ago"},
+		{15 * 24 * time.Hour, "2 weeks ago"},
+		{-3 * 24 * time.Hour, "in 2 days"},
+		{800 * 24 * time.Hour, "2 years ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if have := since(time.Now().Add(-tt.in)); have != tt.want {
+				t.Errorf("\nhave: %q\nwant: %q", have, tt.want)
+			}
+		})
+	}
+	if have := since(time.Time{}); have != "?" {
+		t.Error(have)
+	}
+}
+
+func TestPrint0(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Newline in filename.
+	}
+	defer clearColors()
+	start(t)
+	mkdirAll(t, "dir/sub")
+	touch(t, "new\nline")
+	touch(t, "dir/file")
+
+	{
+		have := mustRun(t, "-0", "-R", "-color=always")
+		want := "dir\x00new\nline\x00dir/file\x00dir/sub\x00"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+	{ // Don't pass names as flags to "xargs -0 rm".
+		touch(t, "-rf")
+		have := mustRun(t, "-0", "./-rf", "dir")
+		want := "./-rf\x00dir/file\x00dir/sub\x00"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+		have = mustRun(t, "-0")
+		want = "./-rf\x00dir\x00new\nline\x00"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+	{
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := mustRun(t, "-print0", "-abs", "dir/file")
+		want := filepath.Join(wd, "dir/file") + "\x00"
+		if have != want {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	}
+}
+
 func TestQuoteFlag(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		// TODO: split to separate test.
@@ -244,6 +724,58 @@ func TestQuoteFlag(t *testing.T) {
 	}
 }
 
+func TestQuotingStyle(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("filenames must be valid UTF-8 on " + runtime.GOOS)
+	}
+
+	start(t)
+	for _, f := range []string{"$x", "a b", "a\u202eb", "caf\xe9", "it's", "new\nline", "plain"} {
+		touch(t, f)
+	}
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+
+	tests := []struct {
+		flags []string
+		want  string
+	}{
+		{[]string{"-quoting-style=literal"}, `$x|a b|a?b|caf?|it's|new?line|plain`},
+		{[]string{"-quoting-style=shell"}, `'$x'|'a b'|'a?b'|'caf?'|"it's"|'new?line'|plain`},
+		{[]string{"-quoting-style=shell-always"}, `'$x'|'a b'|'a?b'|'caf?'|"it's"|'new?line'|'plain'`},
+		{[]string{"-quoting-style=shell-escape"}, `'$x'|'a b'|'a'$'\xe2\x80\xae''b'|'caf'$'\xe9'|"it's"|'new'$'\n''line'|plain`},
+		{[]string{"-quoting-style=shell-escape-always"}, `'$x'|'a b'|'a'$'\xe2\x80\xae''b'|'caf'$'\xe9'|"it's"|'new'$'\n''line'|'plain'`},
+		{[]string{"-quoting-style=c"}, `"$x"|"a b"|"a\342\200\256b"|"caf\351"|"it's"|"new\nline"|"plain"`},
+		{[]string{"-quoting-style=escape"}, `$x|a\ b|a\342\200\256b|caf\351|it's|new\nline|plain`},
+		{[]string{"-quoting-style=locale"}, `‘$x’|‘a b’|‘a\342\200\256b’|‘caf\351’|‘it's’|‘new\nline’|‘plain’`},
+		{[]string{"-quote-for=bash"}, `'$x'|'a b'|'a'$'\xe2\x80\xae''b'|'caf'$'\xe9'|"it's"|'new'$'\n''line'|plain`},
+		{[]string{"-quote-for=sh"}, `'$x'|'a b'|'a'"$(printf '\342\200\256')"'b'|'caf'"$(printf '\351')"|"it's"|'new|line'|plain`},
+		{[]string{"-quote-for=fish"}, `'$x'|'a b'|'a'\Xe2\X80\Xae'b'|'caf'\Xe9|"it's"|'new'\n'line'|plain`},
+		{[]string{"-quote-for=sh", "-quoting-style=shell-always"}, `'$x'|'a b'|'a?b'|'caf?'|"it's"|'new?line'|'plain'`},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.flags, " "), func(t *testing.T) {
+			have := strings.ReplaceAll(mustRun(t, append(tt.flags, "-1")...), "\n", "|")
+			if have != tt.want {
+				t.Errorf("\nhave: %s\nwant: %s", have, tt.want)
+			}
+		})
+	}
+
+	t.Run("QUOTING_STYLE", func(t *testing.T) {
+		t.Setenv("QUOTING_STYLE", "c")
+		have := mustRun(t, "-1", "plain")
+		if have != `"plain"` {
+			t.Errorf("have: %s", have)
+		}
+		// -Q overrides the environment.
+		t.Setenv("QUOTING_STYLE", "literal")
+		have = mustRun(t, "-1", "-QQ", "plain")
+		if have != `"plain"` {
+			t.Errorf("have: %s", have)
+		}
+	})
+}
+
 func TestLong(t *testing.T) {
 	supportsUtimes(t, true)
 
@@ -258,11 +790,11 @@ func TestLong(t *testing.T) {
 	t.Run("default", func(t *testing.T) {
 		have := mustRun(t, "-l")
 		want := norm(`
-			 1.0M │ 15:04 │ 1M
-			    0 │ 15:04 │ dir
-			 9.8K │ 15:04 │ file
-			    3 │ 15:04 │ ln-dir → dir
-			    4 │ 15:04 │ ln-file → file`,
+			  1.0M │ 15:04 │ 1M
+			     0 │ 15:04 │ dir
+			  9.8K │ 15:04 │ file
+			     3 │ 15:04 │ ln-dir → dir
+			     4 │ 15:04 │ ln-file → file`,
 			"15:04", now.Format("15:04"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -271,11 +803,11 @@ func TestLong(t *testing.T) {
 	t.Run("-T", func(t *testing.T) {
 		have := mustRun(t, "-lT")
 		want := norm(`
-			 1.0M │ 2006-01-02 15:04:05 │ 1M
-			    0 │ 2006-01-02 15:04:05 │ dir
-			 9.8K │ 2006-01-02 15:04:05 │ file
-			    3 │ 2006-01-02 15:04:05 │ ln-dir → dir
-			    4 │ 2006-01-02 15:04:05 │ ln-file → file`,
+			  1.0M │ 2006-01-02 15:04:05 │ 1M
+			     0 │ 2006-01-02 15:04:05 │ dir
+			  9.8K │ 2006-01-02 15:04:05 │ file
+			     3 │ 2006-01-02 15:04:05 │ ln-dir → dir
+			     4 │ 2006-01-02 15:04:05 │ ln-file → file`,
 			"2006-01-02 15:04:05", now.Format("2006-01-02 15:04:05"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -293,11 +825,11 @@ func TestLong(t *testing.T) {
 
 		have := mustRun(t, "-lTT")
 		want := norm(`
-			 1.0M │ 2006-01-02 15:04:05.000000000 -07:00 │ 1M
-			    0 │ 2006-01-02 15:04:05.000000000 -07:00 │ dir
-			 9.8K │ 2006-01-02 15:04:05.000000000 -07:00 │ file
-			    3 │ 2006-01-02 15:04:05.000000000 -07:00 │ ln-dir → dir
-			    4 │ 2006-01-02 15:04:05.000000000 -07:00 │ ln-file → file`,
+			  1.0M │ 2006-01-02 15:04:05.000000000 -07:00 │ 1M
+			     0 │ 2006-01-02 15:04:05.000000000 -07:00 │ dir
+			  9.8K │ 2006-01-02 15:04:05.000000000 -07:00 │ file
+			     3 │ 2006-01-02 15:04:05.000000000 -07:00 │ ln-dir → dir
+			     4 │ 2006-01-02 15:04:05.000000000 -07:00 │ ln-file → file`,
 			"2006-01-02 15:04:05.000000000 -07:00", tt.Format("2006-01-02 15:04:05.000000000 -07:00"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -332,11 +864,11 @@ func TestLongLong(t *testing.T) {
 	t.Run("default", func(t *testing.T) {
 		have := mustRun(t, "-llg")
 		want := norm(`
-			-rw-r--r-- martin tournoij 1.0M Jan _2 15:04 │ 1M
-			-rw-r--r-- martin tournoij    0 Jan _2 15:04 │ dir
-			-rw-r--r-- martin tournoij 9.8K Jan _2 15:04 │ file
-			`+lnkprm+` martin tournoij    3 Jan _2 15:04 │ ln-dir → dir
-			`+lnkprm+` martin tournoij    4 Jan _2 15:04 │ ln-file → file`,
+			-rw-r--r-- martin tournoij  1.0M Jan _2 15:04 │ 1M
+			-rw-r--r-- martin tournoij     0 Jan _2 15:04 │ dir
+			-rw-r--r-- martin tournoij  9.8K Jan _2 15:04 │ file
+			`+lnkprm+` martin tournoij     3 Jan _2 15:04 │ ln-dir → dir
+			`+lnkprm+` martin tournoij     4 Jan _2 15:04 │ ln-file → file`,
 			"Jan _2 15:04", now.Format("Jan _2 15:04"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -346,11 +878,11 @@ func TestLongLong(t *testing.T) {
 	t.Run("-T", func(t *testing.T) {
 		have := mustRun(t, "-llgT")
 		want := norm(`
-			-rw-r--r-- martin tournoij 1.0M 2006-01-02 15:04:05 │ 1M
-			-rw-r--r-- martin tournoij    0 2006-01-02 15:04:05 │ dir
-			-rw-r--r-- martin tournoij 9.8K 2006-01-02 15:04:05 │ file
-			`+lnkprm+` martin tournoij    3 2006-01-02 15:04:05 │ ln-dir → dir
-			`+lnkprm+` martin tournoij    4 2006-01-02 15:04:05 │ ln-file → file`,
+			-rw-r--r-- martin tournoij  1.0M 2006-01-02 15:04:05 │ 1M
+			-rw-r--r-- martin tournoij     0 2006-01-02 15:04:05 │ dir
+			-rw-r--r-- martin tournoij  9.8K 2006-01-02 15:04:05 │ file
+			`+lnkprm+` martin tournoij     3 2006-01-02 15:04:05 │ ln-dir → dir
+			`+lnkprm+` martin tournoij     4 2006-01-02 15:04:05 │ ln-file → file`,
 			"2006-01-02 15:04:05", now.Format("2006-01-02 15:04:05"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -360,11 +892,11 @@ func TestLongLong(t *testing.T) {
 	t.Run("-n", func(t *testing.T) {
 		have := mustRun(t, "-llgn")
 		want := norm(`
-			-rw-r--r-- XXXX YYYY 1.0M Jan _2 15:04 │ 1M
-			-rw-r--r-- XXXX YYYY    0 Jan _2 15:04 │ dir
-			-rw-r--r-- XXXX YYYY 9.8K Jan _2 15:04 │ file
-			`+lnkprm+` XXXX YYYY    3 Jan _2 15:04 │ ln-dir → dir
-			`+lnkprm+` XXXX YYYY    4 Jan _2 15:04 │ ln-file → file`,
+			-rw-r--r-- XXXX YYYY  1.0M Jan _2 15:04 │ 1M
+			-rw-r--r-- XXXX YYYY     0 Jan _2 15:04 │ dir
+			-rw-r--r-- XXXX YYYY  9.8K Jan _2 15:04 │ file
+			`+lnkprm+` XXXX YYYY     3 Jan _2 15:04 │ ln-dir → dir
+			`+lnkprm+` XXXX YYYY     4 Jan _2 15:04 │ ln-file → file`,
 			"Jan _2 15:04", now.Format("Jan _2 15:04"),
 			"XXXX", userinfo.UserID,
 			"YYYY", userinfo.GroupID)
@@ -376,11 +908,11 @@ func TestLongLong(t *testing.T) {
 	t.Run("-o", func(t *testing.T) {
 		have := mustRun(t, "-llgo")
 		want := norm(`
-			 644 martin tournoij 1.0M Jan _2 15:04 │ 1M
-			 644 martin tournoij    0 Jan _2 15:04 │ dir
-			 644 martin tournoij 9.8K Jan _2 15:04 │ file
-			`+lnkprmO+` martin tournoij    3 Jan _2 15:04 │ ln-dir → dir
-			`+lnkprmO+` martin tournoij    4 Jan _2 15:04 │ ln-file → file`,
+			 644 martin tournoij  1.0M Jan _2 15:04 │ 1M
+			 644 martin tournoij     0 Jan _2 15:04 │ dir
+			 644 martin tournoij  9.8K Jan _2 15:04 │ file
+			`+lnkprmO+` martin tournoij     3 Jan _2 15:04 │ ln-dir → dir
+			`+lnkprmO+` martin tournoij     4 Jan _2 15:04 │ ln-file → file`,
 			"Jan _2 15:04", now.Format("Jan _2 15:04"))
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
@@ -411,9 +943,9 @@ func TestLongSizeAlignment(t *testing.T) {
 	{
 		have := x(mustRun(t, "-l"))
 		want := strings.ReplaceAll(`
-			    1 │ alloc
-			 121K │ large
-			    0 │ small`[1:], "\t", "")
+			     1 │ alloc
+			 ~121K │ large
+			     0 │ small`[1:], "\t", "")
 		if have != want {
 			t.Errorf("\nhave:\n%s\nwant:\n%s", have, want)
 		}
@@ -442,6 +974,58 @@ func TestLongSizeAlignment(t *testing.T) {
 	}
 }
 
+func TestSparse(t *testing.T) {
+	supportsSparseFiles(t, true)
+	start(t)
+
+	createSparse(t, 123456, "sparse")
+	echoTrunc(t, strings.Repeat("x", 8192), "data")
+	for _, f := range []string{"sparse", "data"} {
+		os.Lchown(f, userinfo.UID, userinfo.GID)
+	}
+
+	{
+		have := mustRun(t, "-llg")
+		want := norm(`
+			-rw-r--r-- martin tournoij    8.0K Jan _2 15:04 │ data
+			-rw-r--r-- martin tournoij ~121K/0 Jan _2 15:04 │ sparse`,
+			"Jan _2 15:04", time.Now().Format("Jan _2 15:04"))
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+
+	{
+		var have struct {
+			Listings []struct {
+				Entries []struct {
+					Name      string `json:"name"`
+					Size      int64  `json:"size"`
+					Allocated int64  `json:"allocated"`
+					DataSize  *int64 `json:"data_size"`
+				} `json:"entries"`
+			} `json:"listings"`
+		}
+		err := json.Unmarshal([]byte(mustRun(t, "-j")), &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, s := have.Listings[0].Entries[0], have.Listings[0].Entries[1]
+		if d.Size != 8192 || d.Allocated < 8192 || d.DataSize != nil {
+			t.Errorf("%#v", d)
+		}
+		if s.Size != 123456 || s.Allocated != 0 {
+			t.Errorf("%#v", s)
+		}
+		switch runtime.GOOS {
+		case "linux", "freebsd", "darwin":
+			if s.DataSize == nil || *s.DataSize != 0 {
+				t.Errorf("%#v", s.DataSize)
+			}
+		}
+	}
+}
+
 func TestSortMtime(t *testing.T) {
 	start(t)
 	touch(t, "a")
@@ -937,8 +1521,6 @@ func TestLFlag(t *testing.T) {
 		t.Skip()
 	}
 
-	testFixedSizeWidth = true
-	defer func() { testFixedSizeWidth = false }()
 	tmp := start(t)
 
 	now := time.Now().Format("15:04")
@@ -953,8 +1535,8 @@ func TestLFlag(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	dsz, _ := listSize(st, "", "", false)
-	repl := []string{"21:35", now, "TMPDIR", tmp, "DIRSZ", fmt.Sprintf("%5s", dsz)}
+	dsz, _ := listSize(fileInfo{FileInfo: st}, "", opts{})
+	repl := []string{"21:35", now, "TMPDIR", tmp, "DIRSZ", dsz}
 
 	{
 		have := mustRun(t, "-CL")
@@ -1121,6 +1703,12 @@ func TestFilesizes(t *testing.T) {
 		createSparse(t, sz, fmt.Sprintf("%d.file", sz))
 	}
 
+	for _, b := range []string{"0", "4X", "K4", "99999999E"} {
+		if have, ok := run(t, "-l", "-B", b); ok {
+			t.Errorf("-B %s: no error: %s", b, have)
+		}
+	}
+
 	run := func(flags ...string) string {
 		var h []string
 		for _, line := range strings.Split(mustRun(t, flags...), "\n") {
@@ -1132,14 +1720,14 @@ func TestFilesizes(t *testing.T) {
 	{
 		have := run("-l")
 		want := norm(`
-			     1 │ 1.file
-			 10.0K │ 10240.file
-			  1.0M │ 1048576.file
-			  1.0G │ 1073741824.file
-			 1024G │ 1099511627776.file
-			  2.0K │ 2048.file
-			   512 │ 512.file
-			  512K │ 524288.file`)
+			      1 │ 1.file
+			 ~10.0K │ 10240.file
+			  ~1.0M │ 1048576.file
+			  ~1.0G │ 1073741824.file
+			  ~1.0T │ 1099511627776.file
+			   2.0K │ 2048.file
+			    512 │ 512.file
+			  ~512K │ 524288.file`)
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
@@ -1176,6 +1764,70 @@ func TestFilesizes(t *testing.T) {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
 	}
+
+	{
+		have := run("-l", "-si")
+		want := norm(`
+			      1 │ 1.file
+			  ~10kB │ 10240.file
+			 ~1.0MB │ 1048576.file
+			 ~1.1GB │ 1073741824.file
+			 ~1.1TB │ 1099511627776.file
+			  2.0kB │ 2048.file
+			    512 │ 512.file
+			 ~524kB │ 524288.file`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+
+	{
+		have := run("-l", "-iec")
+		want := norm(`
+			        1 │ 1.file
+			 ~10.0KiB │ 10240.file
+			  ~1.0MiB │ 1048576.file
+			  ~1.0GiB │ 1073741824.file
+			  ~1.0TiB │ 1099511627776.file
+			   2.0KiB │ 2048.file
+			      512 │ 512.file
+			  ~512KiB │ 524288.file`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+
+	{
+		have := run("-l", "-B", "4K")
+		want := norm(`
+			         1 │ 1.file
+			         3 │ 10240.file
+			       256 │ 1048576.file
+			    262144 │ 1073741824.file
+			 268435456 │ 1099511627776.file
+			         1 │ 2048.file
+			         1 │ 512.file
+			       128 │ 524288.file`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+
+	{
+		have := run("-l", "-B", "MiB")
+		want := norm(`
+			      0.0MiB │ 1.file
+			     ~0.0MiB │ 10240.file
+			     ~1.0MiB │ 1048576.file
+			    ~1024MiB │ 1073741824.file
+			 ~1048576MiB │ 1099511627776.file
+			      0.0MiB │ 2048.file
+			      0.0MiB │ 512.file
+			     ~0.5MiB │ 524288.file`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
 }
 
 func TestTrim(t *testing.T) {
@@ -1213,11 +1865,11 @@ func TestTrim(t *testing.T) {
 		}
 	}
 	{
-		columns = 20
+		columns = 24
 		have := mustRun(t, "-l", "-trim")
 		want := norm(`
-		 0 │ 01:08 │ 012345…
-		 0 │ 01:08 │ 012345…`, "01:08", now)
+		     0 │ 01:08 │ 012345…
+		     0 │ 01:08 │ 012345…`, "01:08", now)
 		if !reflect.DeepEqual(have, want) {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
@@ -1265,19 +1917,19 @@ func TestWidth(t *testing.T) {
 		}
 	}
 	{
-		have := mustRun(t, "-lw20")
+		have := mustRun(t, "-lw24")
 		want := norm(`
-		 0 │ 01:08 │ 012345…
-		 0 │ 01:08 │ 012345…`, "01:08", now)
+		     0 │ 01:08 │ 012345…
+		     0 │ 01:08 │ 012345…`, "01:08", now)
 		if !reflect.DeepEqual(have, want) {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
 	}
 	{
-		have := mustRun(t, "-lw10", "-w21")
+		have := mustRun(t, "-lw10", "-w25")
 		want := norm(`
-		 0 │ 01:08 │ 0123456…
-		 0 │ 01:08 │ 0123456…`, "01:08", now)
+		     0 │ 01:08 │ 0123456…
+		     0 │ 01:08 │ 0123456…`, "01:08", now)
 		if !reflect.DeepEqual(have, want) {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
@@ -1505,15 +2157,17 @@ func TestControlChar(t *testing.T) {
 	{
 		have := mustRun(t, "-CF")
 		want := norm(`
-			$'\x01'  $'\n'    $'\x13'  $'\x1c'               $'\x7f'   $'\x88'/  $'\x91'/
-			$'\x02'  $'\x0b'  $'\x14'  $'\x1d'               $'\x80'/  $'\x89'/  $'\x92'/
-			$'\x03'  $'\x0c'  $'\x15'  $'\x1e'               $'\x81'/  $'\x8a'/  $'\x93'/
-			$'\x04'  $'\r'    $'\x16'  a$'\x01'b$'\x02'      $'\x82'/  $'\x8b'/  $'\x94'/
-			$'\x05'  $'\x0e'  $'\x17'  link$'\x01'b$'\x02'@  $'\x83'/  $'\x8c'/  $'\x95'/
-			$'\x06'  $'\x0f'  $'\x18'  link1@                $'\x84'/  $'\x8d'/  $'\x96'/
-			$'\x07'  $'\x10'  $'\x19'  link2@                $'\x85'/  $'\x8e'/  $'\x97'/
-			$'\x08'  $'\x11'  $'\x1a'  link3@                $'\x86'/  $'\x8f'/
-			$'\t'    $'\x12'  $'\e'    link4@                $'\x87'/  $'\x90'/`)
+			$'\x01'  $'\x0c'  $'\x17'               link2@      $'\u0087'/  $'\u0092'/
+			$'\x02'  $'\r'    $'\x18'               link3@      $'\u0088'/  $'\u0093'/
+			$'\x03'  $'\x0e'  $'\x19'               link4@      $'\u0089'/  $'\u0094'/
+			$'\x04'  $'\x0f'  $'\x1a'               $'\x7f'     $'\u008a'/  $'\u0095'/
+			$'\x05'  $'\x10'  $'\e'                 $'\u0080'/  $'\u008b'/  $'\u0096'/
+			$'\x06'  $'\x11'  $'\x1c'               $'\u0081'/  $'\u008c'/  $'\u0097'/
+			$'\x07'  $'\x12'  $'\x1d'               $'\u0082'/  $'\u008d'/
+			$'\x08'  $'\x13'  $'\x1e'               $'\u0083'/  $'\u008e'/
+			$'\t'    $'\x14'  a$'\x01'b$'\x02'      $'\u0084'/  $'\u008f'/
+			$'\n'    $'\x15'  link$'\x01'b$'\x02'@  $'\u0085'/  $'\u0090'/
+			$'\x0b'  $'\x16'  link1@                $'\u0086'/  $'\u0091'/`)
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
@@ -1531,14 +2185,107 @@ func TestControlChar(t *testing.T) {
 			link1 → a$'\x01'b$'\x02'
 			link2 → $'\n'
 			link3 → $'\x7f'
-			link4 → $'\x81'`)
+			link4 → $'\u0081'`)
 		if have != want {
 			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
 		}
 	}
 }
 
-func TestUnprintable(t *testing.T) {
+func TestInvalidUTF8(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("filenames must be valid UTF-8 on " + runtime.GOOS)
+	}
+
+	start(t)
+	touch(t, "caf\xe9")
+	touch(t, "\xff\xfe x")
+	symlink(t, "caf\xe9", "link")
+
+	{
+		have := mustRun(t, "-1")
+		want := norm(`
+			caf$'\xe9'
+			link
+			$'\xff'$'\xfe' x`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+
+	{
+		var have struct {
+			Listings []struct {
+				Entries []map[string]any
+			}
+		}
+		err := json.Unmarshal([]byte(mustRun(t, "-j")), &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := have.Listings[0].Entries
+		if e[0]["name"] != "caf\ufffd" || e[0]["name_bytes"] != "Y2Fm6Q==" {
+			t.Errorf("name=%q name_bytes=%q", e[0]["name"], e[0]["name_bytes"])
+		}
+		if e[1]["link_target_bytes"] != "Y2Fm6Q==" {
+			t.Errorf("link_target_bytes=%q", e[1]["link_target_bytes"])
+		}
+		if _, ok := e[1]["name_bytes"]; ok {
+			t.Error("name_bytes set for valid UTF-8")
+		}
+	}
+}
+
+func TestDeceptive(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", ""},
+		{"Москва-2024.txt", ""},
+		{"ελληνικά", ""},
+		{"café", ""},
+		{"..", ""},
+		{"txt\u202eexe.js", "bidi"},
+		{"a\u2066b", "bidi"},
+		{"zero\u200bwidth", "invisible"},
+		{"a\u3164b", "invisible"},
+		{"-rf", "leading-dash"},
+		{"file.", "trailing-dot"},
+		{"file ", "trailing-dot"},
+		{"p\u0430ypal", "homoglyph"},
+		{"a\u2215b", "homoglyph"},
+		{"\uff41bc", "homoglyph"},
+		{"-x\u200b.", "invisible,leading-dash,trailing-dot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if have := strings.Join(deceptive(tt.in), ","); have != tt.want {
+				t.Errorf("\nhave: %q\nwant: %q", have, tt.want)
+			}
+		})
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	start(t)
+	touch(t, "txt\u202eexe.js")
+	touch(t, "p\u0430ypal")
+	touch(t, "normal")
+
+	have := mustRun(t, "-suspicious", "-header")
+	want := norm(`
+		Suspicious Name
+		           normal
+		homoglyph  pаypal
+		bidi       txt$'\u202e'exe.js`)
+	if have != want {
+		t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+	}
+}
+
+func TestUnprintable(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Windows doesn't like some of these")
 	}
@@ -1648,6 +2395,518 @@ func TestGroupDirs(t *testing.T) {
 	}
 }
 
+func TestHeader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Directory sizes.
+	}
+	defer func() { columns = 80 }()
+	columns = 40
+
+	start(t)
+	echoTrunc(t, strings.Repeat("x", 9999), "file")
+	touch(t, "a")
+	now := time.Now().Format("15:04")
+
+	{
+		have := mustRun(t, "-1", "-header")
+		want := "Name\na\nfile"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-C", "-header")
+		want := "Name  Name\na     file"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-l", "-header")
+		want := norm(`
+			  Size │ Modified │ Name
+			     0 │    15:04 │ a
+			  9.8K │    15:04 │ file`, "15:04", now)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-lc", "-header", "-i", "a")
+		if h := strings.Fields(strings.Split(have, "\n")[0]); !reflect.DeepEqual(h, []string{"Inode", "│", "Size", "│", "Created", "│", "Name"}) {
+			t.Errorf("wrong header: %q", h)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Hidden files, symlinks.
+	}
+	start(t)
+
+	mkdirAll(t, "dir")
+	touch(t, ".hidden")
+	createSparse(t, 2048, "file1")
+	echoTrunc(t, strings.Repeat("x", 1023), "file2")
+	symlink(t, "file1", "link")
+
+	x := func(out string) string {
+		l := strings.Split(out, "\n")
+		return l[len(l)-1]
+	}
+	{
+		have := x(mustRun(t, "-1", "-summary", "file1", "file2"))
+		want := "2 files; 3.0K apparent, "
+		if !strings.HasPrefix(have, want) {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	}
+	{
+		have := x(mustRun(t, "-1", "-summary", "-B1", "file1", "file2"))
+		want := "2 files; 3071 apparent, "
+		if !strings.HasPrefix(have, want) {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	}
+	{
+		have := x(mustRun(t, "-1", "-summary", "dir"))
+		want := "0 entries; 0 apparent, 0 allocated"
+		if have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	}
+	{
+		have := x(mustRun(t, "-1", "-summary"))
+		if !strings.HasPrefix(have, "1 directory, 2 files, 1 symlink; ") || !strings.HasSuffix(have, "; 1 hidden") {
+			t.Errorf("\nhave: %s", have)
+		}
+	}
+	{
+		have := x(mustRun(t, "-1a", "-summary"))
+		if !strings.HasPrefix(have, "1 directory, 3 files, 1 symlink; ") || strings.Contains(have, "hidden") {
+			t.Errorf("\nhave: %s", have)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Symlinks.
+	}
+	start(t)
+
+	mkdirAll(t, "dir")
+	for _, f := range []string{"b.go", "a.go", "README", "notes.txt", "x.unknown"} {
+		touch(t, f)
+	}
+	symlink(t, "a.go", "link")
+	touchDate(t, time.Now().AddDate(-2, 0, 0), "notes.txt")
+
+	tests := []struct {
+		flag, want string
+	}{
+		{"type", `
+			Directories
+			dir
+
+			Files
+			README
+			a.go
+			b.go
+			notes.txt
+			x.unknown
+
+			Symlinks
+			link`},
+		{"ext", `
+			No extension
+			README
+			dir
+			link
+
+			Go sources
+			a.go
+			b.go
+
+			Text files
+			notes.txt
+
+			*.unknown
+			x.unknown`},
+		{"mdate", `
+			Modified today
+			README
+			a.go
+			b.go
+			dir
+			link
+			x.unknown
+
+			Modified more than a year ago
+			notes.txt`},
+		{"owner", `
+			Owned by martin
+			README
+			a.go
+			b.go
+			dir
+			link
+			notes.txt
+			x.unknown`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			have := mustRun(t, "-1", "-group="+tt.flag)
+			want := norm(tt.want)
+			if have != want {
+				t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+			}
+		})
+	}
+
+	_, ok := run(t, "-group=xxx")
+	if ok {
+		t.Error("ok is true")
+	}
+}
+
+func TestDu(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // Hardlinks
+	}
+	start(t)
+
+	mkdirAll(t, "dir/sub")
+	echoTrunc(t, strings.Repeat("x", 100), "dir/file")
+	echoTrunc(t, strings.Repeat("x", 200), "dir/sub/file")
+	echoTrunc(t, strings.Repeat("x", 250), "file")
+	if err := os.Link("dir/file", "dir/sub/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	size := func(p string) int64 {
+		st, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.Size()
+	}
+	var (
+		sub = size("dir/sub") + 200 + 100
+		dir = size("dir") + sub // dir/file is the same as dir/sub/link.
+	)
+
+	have := mustRun(t, "-1l", "-B1", "-du", "-S", "-R")
+	want := norm(`
+		.:
+		DIR │ 15:04 │ dir
+		250 │ 15:04 │ file
+
+		dir:
+		SUB │ 15:04 │ sub
+		100 │ 15:04 │ file
+
+		dir/sub:
+		200 │ 15:04 │ file
+		100 │ 15:04 │ link`,
+		"DIR", fmt.Sprintf("%d", dir), "SUB", fmt.Sprintf("%d", sub), "15:04", time.Now().Format("15:04"))
+	x := func(s string) string {
+		s = strings.ReplaceAll(s, " ", "")
+		return strings.ReplaceAll(s, "│", " ")
+	}
+	if x(have) != x(want) {
+		t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+	}
+}
+
+func TestDuOneFS(t *testing.T) {
+	tmp := start(t)
+	mkdirAll(t, "dir/mnt/sub")
+	echoTrunc(t, strings.Repeat("x", 100), "dir/file")
+	echoTrunc(t, strings.Repeat("x", 200), "dir/mnt/file")
+
+	size := func(p string) int64 {
+		st, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.Size()
+	}
+	// Pretend dir/mnt is on a different filesystem.
+	device := func(fi fs.FileInfo) uint64 {
+		if fi.Name() == "mnt" {
+			return 2
+		}
+		return 1
+	}
+
+	for _, tt := range []struct {
+		oneFS bool
+		want  int64
+	}{
+		{false, 100 + size("dir/mnt") + size("dir/mnt/sub") + 200},
+		{true, 100},
+	} {
+		t.Run(fmt.Sprintf("%t", tt.oneFS), func(t *testing.T) {
+			errs := &errGroup{}
+			d := newDuWalker(errs, tt.oneFS)
+			d.device = device
+			have := d.get(filepath.Join(tmp, "dir"), 1).total().apparent
+			if errs.Len() > 0 {
+				t.Fatal(errs)
+			}
+			if have != tt.want {
+				t.Errorf("have %d; want %d", have, tt.want)
+			}
+		})
+	}
+
+	// Every directory is walked only once with -R.
+	t.Run("walk once", func(t *testing.T) {
+		var (
+			errs  = &errGroup{}
+			d     = newDuWalker(errs, false)
+			mu    sync.Mutex
+			walks = make(map[string]int)
+		)
+		d.readDir = func(path string) ([]os.DirEntry, error) {
+			mu.Lock()
+			walks[path]++
+			mu.Unlock()
+			return os2.ReadDir(path)
+		}
+		toPrint := gather([]string{"."}, errs, false, true, false, false, false, false, nil)
+		if len(toPrint) != 4 {
+			t.Fatalf("len(toPrint) = %d", len(toPrint))
+		}
+		d.fill(toPrint)
+		if errs.Len() > 0 {
+			t.Fatal(errs)
+		}
+
+		want := map[string]int{
+			filepath.Join(tmp, "dir"):         1,
+			filepath.Join(tmp, "dir/mnt"):     1,
+			filepath.Join(tmp, "dir/mnt/sub"): 1,
+		}
+		if !reflect.DeepEqual(walks, want) {
+			t.Errorf("\nhave: %v\nwant: %v", walks, want)
+		}
+	})
+}
+
+func TestCount(t *testing.T) {
+	start(t)
+
+	mkdirAll(t, "a/sub1")
+	mkdirAll(t, "a/sub2")
+	touch(t, "a/file")
+	touch(t, "a/.hidden")
+	mkdirAll(t, "b")
+	touch(t, "b/file")
+	touch(t, "file")
+
+	{
+		have := mustRun(t, "-1", "-count")
+		want := norm(`
+			3 a
+			1 b
+			  file`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-1a", "-count-split", "-sort=count", "-r")
+		want := norm(`
+			      file
+			0d 1f b
+			2d 2f a`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		var have struct {
+			Listings []struct {
+				Entries []struct {
+					Name     string         `json:"name"`
+					Children map[string]int `json:"children"`
+				} `json:"entries"`
+			} `json:"listings"`
+		}
+		err := json.Unmarshal([]byte(mustRun(t, "-j", "-count")), &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []map[string]int{{"files": 1, "dirs": 2}, {"files": 1, "dirs": 0}, nil}
+		for i, e := range have.Listings[0].Entries {
+			if !reflect.DeepEqual(e.Children, want[i]) {
+				t.Errorf("%s: %v", e.Name, e.Children)
+			}
+		}
+	}
+}
+
+func TestHardlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip() // TODO: os2.Hardlink isn't implemented.
+	}
+	start(t)
+
+	touch(t, "a")
+	touch(t, "b")
+	touch(t, "c")
+	mkdirAll(t, "dir")
+	for _, l := range [][]string{{"a", "z"}, {"b", "y"}, {"a", "dir/x"}} {
+		if err := os.Link(l[0], l[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		have := mustRun(t, "-1", "-links", "-hardlinks")
+		want := norm(`
+			3 #1 a
+			2 #2 b
+			1    c
+			2    dir
+			2 #2 y
+			3 #1 z`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-1", "-hardlinks", "-hardlinks")
+		want := norm(`
+			#1×2 a
+			#2×2 b
+			     c
+			     dir`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-1", "-hardlinks", "a", "dir")
+		want := norm(`
+			#1 a
+
+			dir:
+			#1 x`)
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+}
+
+func TestAccess(t *testing.T) {
+	start(t)
+
+	touch(t, "a")
+	touch(t, "b")
+	chmod(t, 0o444, "b")
+	mkdirAll(t, "c")
+
+	// root can write to anything.
+	root := os.Geteuid() == 0
+	{
+		have := mustRun(t, "-1", "-access")
+		want := "rw- a\nr-- b\nrwx c"
+		if root {
+			want = "rw- a\nrw- b\nrwx c"
+		}
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-1", "-writable", "-summary")
+		want, wantSum := "a\nc\n", "; 1 filtered"
+		if root {
+			want, wantSum = "a\nb\nc\n", " allocated"
+		}
+		if !strings.HasPrefix(have, want) || !strings.HasSuffix(have, wantSum) {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s…%s", have, want, wantSum)
+		}
+	}
+	{
+		have := mustRun(t, "-1", "-unwritable")
+		want := "b"
+		if root {
+			want = ""
+		}
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{ // Directories are still recursed in to if they're filtered.
+		touch(t, "c/d")
+		chmod(t, 0o444, "c/d")
+		have := mustRun(t, "-1", "-R", "-unwritable")
+		want := ".:\nb\n\nc:\nd"
+		if root {
+			want = ".:\n\nc:"
+		}
+		if have != want {
+			t.Errorf("\nhave:\n%q\n\nwant:\n%q", have, want)
+		}
+	}
+	{
+		have, ok := run(t, "-writable", "-unwritable")
+		if ok || !strings.Contains(have, "can't use -writable and -unwritable together") {
+			t.Errorf("wrong output: %q", have)
+		}
+	}
+}
+
+func TestBars(t *testing.T) {
+	tests := []struct {
+		sz, scale int64
+		want      string
+	}{
+		{0, 100, "          "},
+		{-1, 100, "          "},
+		{1, 0, "          "},
+		{1, 1000, "▏         "},
+		{100, 100, "██████████"},
+		{50, 100, "█████     "},
+		{55, 100, "█████▌    "},
+		{99, 100, "█████████▉"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			have := bar(tt.sz, tt.scale)
+			if have != tt.want {
+				t.Errorf("bar(%d, %d)\nhave: %q\nwant: %q", tt.sz, tt.scale, have, tt.want)
+			}
+		})
+	}
+
+	start(t)
+	echoTrunc(t, strings.Repeat("x", 100), "a")
+	echoTrunc(t, strings.Repeat("x", 50), "b")
+	echoTrunc(t, strings.Repeat("x", 50), "c")
+	touch(t, "d")
+
+	{
+		have := mustRun(t, "-1", "-bars")
+		want := "██████████ a\n█████      b\n█████      c\n           d"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+	{
+		have := mustRun(t, "-1", "-bars-total")
+		want := "█████      a\n██▌        b\n██▌        c\n           d"
+		if have != want {
+			t.Errorf("\nhave:\n%s\n\nwant:\n%s", have, want)
+		}
+	}
+}
+
 // Make sure 'ls' and 'ls -R' do the right thing when invoked with no arguments.
 func TestWithoutArguments(t *testing.T) {
 	start(t)
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zgo.at/zli"
+)
+
+// The colours for the CSS classes from fileClass; these are the GNU defaults.
+const htmlStyle = `
+	body               { font-family: monospace; }
+	table.elles        { border-collapse: collapse; }
+	table.elles th     { text-align: left; border-bottom: 1px solid #999; }
+	table.elles td     { padding: 0 .6em; white-space: pre; }
+	table.elles .num   { text-align: right; }
+	ul.elles           { list-style: none; padding: 0; columns: 4; }
+	ul.elles li        { white-space: pre; }
+	a                  { color: inherit; }
+	.di                { color: #1c4fbe; font-weight: bold; }
+	.ln                { color: #1a9aa0; font-weight: bold; }
+	.or                { color: #c01c28; font-weight: bold; }
+	.fi, .mh           { color: inherit; }
+	.ex                { color: #26a269; font-weight: bold; }
+	.ca                { color: #000; background-color: #c01c28; }
+	.pi                { color: #a2734c; }
+	.so, .do           { color: #a347ba; font-weight: bold; }
+	.bd, .cd           { color: #a2734c; font-weight: bold; }
+	.su                { color: #fff; background-color: #c01c28; }
+	.sg                { color: #000; background-color: #e5a50a; }
+	.st                { color: #fff; background-color: #1c4fbe; }
+	.ow                { color: #1c4fbe; background-color: #26a269; }
+	.tw                { color: #000; background-color: #26a269; }
+	.hidden, .sparse   { opacity: .6; }
+	.suspicious        { color: #fff; background-color: #c01c28; }
+`
+
+// Get the CSS classes for -format=html: the LS_COLORS key from typeClass (with
+// "or" for orphaned symlinks), and "hidden", "sparse", and "suspicious" as
+// needed. Extension colours such as "*.tar" aren't supported, same as for the
+// normal output.
+func fileClass(absdir string, fi fileInfo) []string {
+	var (
+		c []string
+		t = typeClass(absdir, fi, true, true)
+	)
+	if t == "ln" {
+		if _, err := os.Stat(filepath.Join(absdir, fi.Name())); err != nil {
+			t = "or"
+		}
+	}
+	if t != "" {
+		c = append(c, t)
+	}
+	if strings.HasPrefix(fi.Name(), ".") {
+		c = append(c, "hidden")
+	}
+	if isSparse(fi) {
+		c = append(c, "sparse")
+	}
+	if deceptive(fi.Name()) != nil {
+		c = append(c, "suspicious")
+	}
+	return c
+}
+
+// Get the index of the Name column in the header.
+func nameColumn(hdr []col) int {
+	for i, c := range hdr {
+		if c.s == "Name" {
+			return i
+		}
+	}
+	return -1
+}
+
+// Print the listing as a HTML document for -format=html: long listings (or
+// any listing with more than one column) are written as a table, and short
+// listings as a list. Names are in a <span> or <a> (with -hyperlink) with
+// the classes from fileClass.
+func printHTML(toPrint []printable, errs *errGroup, opt opts) {
+	var (
+		w      = bufio.NewWriter(zli.Stdout)
+		link   = opt.hyperlink
+		titles = make([]string, 0, len(toPrint))
+	)
+	opt.header, opt.hyperlink, opt.group = true, false, true
+	if opt.quote.style == "" && opt.quote.level == 0 {
+		opt.quote.style = "literal"
+	}
+	for _, p := range toPrint {
+		if p.dir != "" {
+			titles = append(titles, filepath.ToSlash(filepath.Clean(p.dir)))
+		}
+	}
+	if link {
+		hostnameOnce.Do(func() { h, _ := os.Hostname(); hostname = esc(h) })
+	}
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n",
+		html.EscapeString(strings.Join(titles, ", ")), htmlStyle)
+	for _, p := range toPrint {
+		if len(toPrint) > 1 && p.dir != "" {
+			fmt.Fprintf(w, "<h2>%s</h2>\n", html.EscapeString(filepath.ToSlash(filepath.Clean(p.dir))))
+		}
+		cc := getCols(p, opt)
+		if len(cc.rows) == 0 {
+			continue
+		}
+
+		nameCol := nameColumn(cc.header)
+		name := func(i int, s string) string {
+			fi := p.fi[i]
+			afp := p.absdir
+			if p.isFiles {
+				afp = fi.filepathAbs
+			}
+			cls := strings.Join(fileClass(afp, fi), " ")
+			s = html.EscapeString(strings.TrimSpace(s))
+			if link {
+				return fmt.Sprintf(`<a class="%s" href="file://%s%s">%s</a>`,
+					cls, hostname, html.EscapeString(esc(filepath.ToSlash(p.abs(fi)))), s)
+			}
+			return fmt.Sprintf(`<span class="%s">%s</span>`, cls, s)
+		}
+
+		if len(cc.header) == 1 {
+			w.WriteString("<ul class=\"elles\">\n")
+			for i, r := range cc.rows {
+				fmt.Fprintf(w, "<li>%s</li>\n", name(i, r[0].s))
+			}
+			w.WriteString("</ul>\n")
+			continue
+		}
+
+		w.WriteString("<table class=\"elles\">\n<thead><tr>")
+		for _, c := range cc.header {
+			fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(strings.TrimSpace(c.s)))
+		}
+		w.WriteString("</tr></thead>\n<tbody>\n")
+		for i, r := range cc.rows {
+			w.WriteString("<tr>")
+			for j, c := range r {
+				switch {
+				case j == nameCol:
+					fmt.Fprintf(w, "<td>%s</td>", name(i, c.s))
+				case c.prop&(alignLeft|alignNone) == 0:
+					fmt.Fprintf(w, "<td class=\"num\">%s</td>", html.EscapeString(strings.TrimSpace(c.s)))
+				default:
+					fmt.Fprintf(w, "<td>%s</td>", html.EscapeString(strings.TrimSpace(c.s)))
+				}
+			}
+			w.WriteString("</tr>\n")
+		}
+		w.WriteString("</tbody>\n</table>\n")
+	}
+	w.WriteString("</body>\n</html>\n")
+	zli.F(w.Flush())
+	printErrors(errs)
+}
+
+// Print the columns as a GitHub-flavoured Markdown table for -format=markdown,
+// with a heading for every directory if there's more than one.
+func printMarkdown(toPrint []printable, errs *errGroup, opt opts) {
+	var (
+		w    = bufio.NewWriter(zli.Stdout)
+		repl = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "`", "\\`", `*`, `\*`,
+			`_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`)
+		cell = func(s string) string { return repl.Replace(strings.TrimSpace(s)) }
+	)
+	opt.header, opt.group = true, true
+	if opt.quote.style == "" && opt.quote.level == 0 {
+		opt.quote.style = "literal"
+	}
+	for i, p := range toPrint {
+		if len(toPrint) > 1 && p.dir != "" {
+			if i > 0 {
+				w.WriteByte('\n')
+			}
+			fmt.Fprintf(w, "## %s\n\n", cell(filepath.ToSlash(filepath.Clean(p.dir))))
+		}
+		cc := getCols(p, opt)
+		if len(cc.rows) == 0 {
+			continue
+		}
+
+		w.WriteByte('|')
+		for _, c := range cc.header {
+			fmt.Fprintf(w, " %s |", cell(c.s))
+		}
+		w.WriteString("\n|")
+		for _, c := range cc.header {
+			if c.prop&(alignLeft|alignNone) == 0 {
+				w.WriteString(" ---: |")
+			} else {
+				w.WriteString(" --- |")
+			}
+		}
+		w.WriteByte('\n')
+		for _, r := range cc.rows {
+			w.WriteByte('|')
+			for _, c := range r {
+				fmt.Fprintf(w, " %s |", cell(c.s))
+			}
+			w.WriteByte('\n')
+		}
+	}
+	zli.F(w.Flush())
+	printErrors(errs)
+}
@@ -0,0 +1,12 @@
+//go:build !unix || aix
+
+package os2
+
+import "io/fs"
+
+// Access reports if the current user can read, write, and execute path. This
+// is only based on the owner permission bits of fi.
+func Access(path string, fi fs.FileInfo) (r, w, x bool) {
+	m := fi.Mode().Perm()
+	return m&0o400 != 0, m&0o200 != 0, m&0o100 != 0
+}
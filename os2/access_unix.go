@@ -0,0 +1,19 @@
+//go:build unix && !aix
+
+package os2
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// Access reports if the current user can read, write, and execute path, as
+// faccessat() with AT_EACCESS. This accounts for the effective UID and GID,
+// supplementary groups, ACLs, and read-only mounts.
+func Access(path string, fi fs.FileInfo) (r, w, x bool) {
+	ok := func(mode uint32) bool {
+		return unix.Faccessat(unix.AT_FDCWD, path, mode, unix.AT_EACCESS) == nil
+	}
+	return ok(unix.R_OK), ok(unix.W_OK), ok(unix.X_OK)
+}
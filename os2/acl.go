@@ -0,0 +1,95 @@
+package os2
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+type (
+	// ACL is a POSIX.1e access control list.
+	ACL []ACLEntry
+
+	// ACLEntry is a single entry in an ACL.
+	ACLEntry struct {
+		Tag  ACLTag
+		ID   uint32 // UID or GID for ACLUser and ACLGroup.
+		Perm uint8  // Bitmask of 4 (read), 2 (write), 1 (execute).
+	}
+
+	ACLTag uint16
+)
+
+// ACL tags, as in the Linux xattr format.
+const (
+	ACLUserObj  ACLTag = 0x01
+	ACLUser     ACLTag = 0x02
+	ACLGroupObj ACLTag = 0x04
+	ACLGroup    ACLTag = 0x08
+	ACLMask     ACLTag = 0x10
+	ACLOther    ACLTag = 0x20
+)
+
+func (t ACLTag) String() string {
+	switch t {
+	case ACLUserObj, ACLUser:
+		return "user"
+	case ACLGroupObj, ACLGroup:
+		return "group"
+	case ACLMask:
+		return "mask"
+	case ACLOther:
+		return "other"
+	default:
+		return "tag" + strconv.Itoa(int(t))
+	}
+}
+
+// Extended reports if the ACL has entries beyond the owner, group, and other
+// permissions that are already in the file mode.
+func (a ACL) Extended() bool {
+	for _, e := range a {
+		if e.Tag == ACLUser || e.Tag == ACLGroup || e.Tag == ACLMask {
+			return true
+		}
+	}
+	return false
+}
+
+// PermString formats the permissions as "rwx".
+func (e ACLEntry) PermString() string {
+	b := []byte("---")
+	if e.Perm&4 != 0 {
+		b[0] = 'r'
+	}
+	if e.Perm&2 != 0 {
+		b[1] = 'w'
+	}
+	if e.Perm&1 != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// ParseACL parses the binary format of the system.posix_acl_access and
+// system.posix_acl_default xattrs on Linux: a 4-byte version header followed
+// by 8-byte entries of {u16 tag, u16 perm, u32 id}, all little-endian.
+func ParseACL(b []byte) (ACL, error) {
+	if len(b) < 4 || (len(b)-4)%8 != 0 {
+		return nil, errors.New("invalid ACL: wrong size")
+	}
+	if v := binary.LittleEndian.Uint32(b); v != 2 {
+		return nil, errors.New("invalid ACL: unknown version " + strconv.Itoa(int(v)))
+	}
+	b = b[4:]
+	acl := make(ACL, 0, len(b)/8)
+	for len(b) > 0 {
+		acl = append(acl, ACLEntry{
+			Tag:  ACLTag(binary.LittleEndian.Uint16(b)),
+			Perm: uint8(binary.LittleEndian.Uint16(b[2:])),
+			ID:   binary.LittleEndian.Uint32(b[4:]),
+		})
+		b = b[8:]
+	}
+	return acl, nil
+}
@@ -0,0 +1,25 @@
+package os2
+
+// ACLs gets the access and default ACL for path; either may be nil if it has
+// no ACL.
+func ACLs(path string) (access, def ACL, err error) {
+	for _, a := range []struct {
+		name string
+		acl  *ACL
+	}{
+		{"system.posix_acl_access", &access},
+		{"system.posix_acl_default", &def},
+	} {
+		b, err := GetXattr(path, a.name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b != nil {
+			*a.acl, err = ParseACL(b)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return access, def, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package os2
+
+// TODO: the BSDs, macOS, and illumos all have ACLs, but they're not stored as
+// xattrs and need acl_get_link() or similar.
+
+func ACLs(path string) (access, def ACL, err error) { return nil, nil, nil }
@@ -0,0 +1,128 @@
+package os2
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Capabilities are Linux file capabilities, from the security.capability
+// xattr.
+type Capabilities struct {
+	Version     int    // Format version: 1, 2, or 3.
+	Permitted   uint64 // Bitmask of capabilities, in the order of CapNames.
+	Inheritable uint64
+	Effective   bool   // All permitted and inheritable capabilities are effective.
+	RootID      uint32 // UID of root in the user namespace; only for version 3.
+}
+
+// CapNames are the capability names, indexed by number.
+var CapNames = []string{"chown", "dac_override", "dac_read_search", "fowner",
+	"fsetid", "kill", "setgid", "setuid", "setpcap", "linux_immutable",
+	"net_bind_service", "net_broadcast", "net_admin", "net_raw", "ipc_lock",
+	"ipc_owner", "sys_module", "sys_rawio", "sys_chroot", "sys_ptrace",
+	"sys_pacct", "sys_admin", "sys_boot", "sys_nice", "sys_resource", "sys_time",
+	"sys_tty_config", "mknod", "lease", "audit_write", "audit_control",
+	"setfcap", "mac_override", "mac_admin", "syslog", "wake_alarm",
+	"block_suspend", "audit_read", "perfmon", "bpf", "checkpoint_restore"}
+
+// GetCapabilities gets the file capabilities for path; it returns nil if there
+// are none.
+func GetCapabilities(path string) (*Capabilities, error) {
+	b, err := GetXattr(path, "security.capability")
+	if err != nil || b == nil {
+		return nil, err
+	}
+	c, err := ParseCapabilities(b)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseCapabilities parses the security.capability xattr, which is a
+// little-endian struct vfs_cap_data: a u32 with the version in the top byte
+// and the effective flag in the lowest bit, followed by one (v1) or two (v2,
+// v3) pairs of u32 permitted and inheritable sets, and for v3 the u32 rootid.
+func ParseCapabilities(b []byte) (Capabilities, error) {
+	if len(b) < 4 {
+		return Capabilities{}, errors.New("invalid capabilities: too short")
+	}
+	var (
+		magic = binary.LittleEndian.Uint32(b)
+		c     = Capabilities{Version: int(magic >> 24), Effective: magic&1 != 0}
+		want  int
+	)
+	switch c.Version {
+	case 1:
+		want = 12
+	case 2:
+		want = 20
+	case 3:
+		want = 24
+	default:
+		return Capabilities{}, errors.New("invalid capabilities: unknown version " + strconv.Itoa(c.Version))
+	}
+	if len(b) != want {
+		return Capabilities{}, errors.New("invalid capabilities: wrong size for version " + strconv.Itoa(c.Version))
+	}
+
+	c.Permitted = uint64(binary.LittleEndian.Uint32(b[4:]))
+	c.Inheritable = uint64(binary.LittleEndian.Uint32(b[8:]))
+	if c.Version >= 2 {
+		c.Permitted |= uint64(binary.LittleEndian.Uint32(b[12:])) << 32
+		c.Inheritable |= uint64(binary.LittleEndian.Uint32(b[16:])) << 32
+	}
+	if c.Version == 3 {
+		c.RootID = binary.LittleEndian.Uint32(b[20:])
+	}
+	return c, nil
+}
+
+// String formats the capabilities like getcap, e.g. "cap_net_raw=ep" or
+// "cap_chown,cap_kill=ep cap_setuid=i". The rootid is added as
+// " [rootid=1000]" if it's not 0.
+func (c Capabilities) String() string {
+	var (
+		b     strings.Builder
+		order []string
+		caps  = make(map[string][]string)
+	)
+	for i := range 64 {
+		m := uint64(1) << i
+		if (c.Permitted|c.Inheritable)&m == 0 {
+			continue
+		}
+		var fl string
+		if c.Effective {
+			fl += "e"
+		}
+		if c.Inheritable&m != 0 {
+			fl += "i"
+		}
+		if c.Permitted&m != 0 {
+			fl += "p"
+		}
+		if _, ok := caps[fl]; !ok {
+			order = append(order, fl)
+		}
+		name := "cap_" + strconv.Itoa(i)
+		if i < len(CapNames) {
+			name = "cap_" + CapNames[i]
+		}
+		caps[fl] = append(caps[fl], name)
+	}
+	for _, fl := range order {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.Join(caps[fl], ","))
+		b.WriteByte('=')
+		b.WriteString(fl)
+	}
+	if c.RootID != 0 {
+		b.WriteString(" [rootid=" + strconv.FormatUint(uint64(c.RootID), 10) + "]")
+	}
+	return b.String()
+}
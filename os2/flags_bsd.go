@@ -0,0 +1,51 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package os2
+
+import (
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// File flags with the names that ls -lo uses. Not all systems support all
+// flags, but the values are the same everywhere.
+var fileFlags = []struct {
+	flag uint32
+	name string
+}{
+	{0x00010000, "arch"},       // SF_ARCHIVED
+	{0x00020000, "schg"},       // SF_IMMUTABLE
+	{0x00040000, "sappnd"},     // SF_APPEND
+	{0x00080000, "restricted"}, // SF_RESTRICTED (macOS)
+	{0x00100000, "sunlnk"},     // SF_NOUNLINK
+	{0x00200000, "snapshot"},   // SF_SNAPSHOT (FreeBSD)
+	{0x00000001, "nodump"},     // UF_NODUMP
+	{0x00000002, "uchg"},       // UF_IMMUTABLE
+	{0x00000004, "uappnd"},     // UF_APPEND
+	{0x00000008, "opaque"},     // UF_OPAQUE
+	{0x00000010, "uunlnk"},     // UF_NOUNLINK
+	{0x00000020, "compressed"}, // UF_COMPRESSED (macOS)
+	{0x00008000, "hidden"},     // UF_HIDDEN
+}
+
+// FileFlags gets the file flags (st_flags) in the same format as ls -lo, e.g.
+// "schg,nodump", or "-" if there are no flags.
+func FileFlags(path string, fi fs.FileInfo) (string, error) {
+	if fi.Sys() == nil {
+		return "", nil
+	}
+	var (
+		flags = uint32(fi.Sys().(*syscall.Stat_t).Flags)
+		names []string
+	)
+	for _, f := range fileFlags {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return "-", nil
+	}
+	return strings.Join(names, ","), nil
+}
@@ -0,0 +1,80 @@
+package os2
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// Inode flags as displayed by lsattr, in the same order.
+var inodeFlags = []struct {
+	flag   uint32
+	letter byte
+}{
+	{0x00000001, 's'}, // FS_SECRM_FL
+	{0x00000002, 'u'}, // FS_UNRM_FL
+	{0x00000008, 'S'}, // FS_SYNC_FL
+	{0x00010000, 'D'}, // FS_DIRSYNC_FL
+	{0x00000010, 'i'}, // FS_IMMUTABLE_FL
+	{0x00000020, 'a'}, // FS_APPEND_FL
+	{0x00000040, 'd'}, // FS_NODUMP_FL
+	{0x00000080, 'A'}, // FS_NOATIME_FL
+	{0x00000004, 'c'}, // FS_COMPR_FL
+	{0x00000800, 'E'}, // FS_ENCRYPT_FL
+	{0x00004000, 'j'}, // FS_JOURNAL_DATA_FL
+	{0x00001000, 'I'}, // FS_INDEX_FL
+	{0x00008000, 't'}, // FS_NOTAIL_FL
+	{0x00020000, 'T'}, // FS_TOPDIR_FL
+	{0x00080000, 'e'}, // FS_EXTENT_FL
+	{0x00800000, 'C'}, // FS_NOCOW_FL
+	{0x02000000, 'x'}, // FS_DAX_FL
+	{0x40000000, 'F'}, // FS_CASEFOLD_FL
+	{0x10000000, 'N'}, // FS_INLINE_DATA_FL
+	{0x20000000, 'P'}, // FS_PROJINHERIT_FL
+	{0x00100000, 'V'}, // FS_VERITY_FL
+	{0x00000400, 'm'}, // FS_NOCOMP_FL
+}
+
+// FileFlags gets the inode flags ("chattr attributes") in the same format as
+// lsattr, e.g. "----i---------e-------".
+//
+// This uses the FS_IOC_GETFLAGS ioctl for regular files and directories, which
+// requires opening the file. If that fails it falls back to the attributes
+// from statx(), which only has a subset of the flags.
+func FileFlags(path string, fi fs.FileInfo) (string, error) {
+	var (
+		flags uint32
+		err   error = unix.ENOTSUP
+	)
+	if fi.Mode().IsRegular() || fi.IsDir() {
+		var fd int
+		fd, err = unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err == nil {
+			flags, err = unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+			unix.Close(fd)
+		}
+	}
+	if err != nil {
+		var s unix.Statx_t
+		if err := unix.Statx(0, path, unix.AT_SYMLINK_NOFOLLOW, 0, &s); err != nil {
+			return "", err
+		}
+		// The statx attributes use the same values as FS_*_FL, except DAX.
+		a := s.Attributes & s.Attributes_mask
+		flags = uint32(a & (unix.STATX_ATTR_COMPRESSED | unix.STATX_ATTR_IMMUTABLE |
+			unix.STATX_ATTR_APPEND | unix.STATX_ATTR_NODUMP | unix.STATX_ATTR_ENCRYPTED |
+			unix.STATX_ATTR_VERITY))
+		if a&unix.STATX_ATTR_DAX != 0 {
+			flags |= 0x02000000
+		}
+	}
+
+	b := make([]byte, len(inodeFlags))
+	for i, f := range inodeFlags {
+		b[i] = '-'
+		if flags&f.flag != 0 {
+			b[i] = f.letter
+		}
+	}
+	return string(b), nil
+}
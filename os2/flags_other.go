@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package os2
+
+import "io/fs"
+
+func FileFlags(path string, fi fs.FileInfo) (string, error) { return "", nil }
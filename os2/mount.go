@@ -0,0 +1,191 @@
+package os2
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mount is a mounted filesystem.
+type Mount struct {
+	ID           int      // Mount ID; only on Linux.
+	Source       string   // Device or other source, e.g. "/dev/sda1" or "tmpfs".
+	Path         string   // Mount point.
+	Type         string   // Filesystem type, e.g. "ext4"; can be empty if unknown.
+	Options      []string // Mount options, e.g. "ro" or "noexec".
+	SuperOptions []string // Superblock options; only on Linux.
+}
+
+// Option reports if the mount or superblock option name is set.
+func (m Mount) Option(name string) bool {
+	return slices.Contains(m.Options, name) || slices.Contains(m.SuperOptions, name)
+}
+
+// ReadOnly reports if the filesystem is mounted read-only.
+func (m Mount) ReadOnly() bool { return m.Option("ro") }
+
+// NoExec reports if the filesystem is mounted with noexec.
+func (m Mount) NoExec() bool { return m.Option("noexec") }
+
+var (
+	mnts     []Mount
+	mntsOnce sync.Once
+)
+
+// Mounts gets all mounted filesystems, in the order they were mounted. This is
+// read once and cached.
+func Mounts() []Mount {
+	mntsOnce.Do(func() { mnts = readMounts() })
+	return mnts
+}
+
+// MountFor gets the mount that path resides on: the mount with the longest
+// path that's a parent of path. If there are several mounts on the same path
+// the last one is used, as that's the one that's visible.
+//
+// Symlinks in path are not resolved.
+func MountFor(path string) (Mount, bool) {
+	i := mountIndex(path)
+	if i == -1 {
+		return Mount{}, false
+	}
+	return mnts[i], true
+}
+
+// IsMountPoint reports if path is a mount point.
+func IsMountPoint(path string) bool {
+	i := mountIndex(path)
+	return i > -1 && mnts[i].Path == path
+}
+
+func mountIndex(path string) int {
+	found := -1
+	for i, m := range Mounts() {
+		if m.Path != path && m.Path != "/" && !strings.HasPrefix(path, m.Path+"/") {
+			continue
+		}
+		if found == -1 || len(m.Path) >= len(mnts[found].Path) {
+			found = i
+		}
+	}
+	return found
+}
+
+// ParseMountinfo parses the Linux /proc/self/mountinfo format:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// The fields are: mount ID, parent ID, major:minor, root, mount point, mount
+// options, zero or more optional fields terminated by "-", filesystem type,
+// source, and superblock options.
+func ParseMountinfo(r io.Reader) ([]Mount, error) {
+	var (
+		scan = bufio.NewScanner(r)
+		m    = make([]Mount, 0, 16)
+	)
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		sep := slices.Index(f, "-")
+		if len(f) < 6 || sep < 6 || len(f) < sep+3 {
+			continue
+		}
+		id, _ := strconv.Atoi(f[0])
+		mnt := Mount{
+			ID:      id,
+			Path:    unescapeMount(f[4]),
+			Options: strings.Split(f[5], ","),
+			Type:    unescapeMount(f[sep+1]),
+			Source:  unescapeMount(f[sep+2]),
+		}
+		if len(f) > sep+3 {
+			mnt.SuperOptions = strings.Split(f[sep+3], ",")
+		}
+		m = append(m, mnt)
+	}
+	return m, scan.Err()
+}
+
+// ParseMounts parses the fstab format used by /proc/mounts on Linux and
+// /etc/mnttab on illumos:
+//
+//	/dev/sda1 / ext4 rw,relatime 0 0
+func ParseMounts(r io.Reader) ([]Mount, error) {
+	var (
+		scan = bufio.NewScanner(r)
+		m    = make([]Mount, 0, 16)
+	)
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		if len(f) < 4 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		m = append(m, Mount{
+			Source:  unescapeMount(f[0]),
+			Path:    unescapeMount(f[1]),
+			Type:    unescapeMount(f[2]),
+			Options: strings.Split(f[3], ","),
+		})
+	}
+	return m, scan.Err()
+}
+
+// ParseMountCmd parses the output of mount(8) on BSD systems and macOS:
+//
+//	/dev/ada0p2 on / (ufs, local, soft-updates)     FreeBSD, macOS
+//	/dev/sd0a on / type ffs (local, nodev)          OpenBSD, NetBSD
+//
+// "read-only" is reported as the "ro" option.
+func ParseMountCmd(out string) []Mount {
+	m := make([]Mount, 0, 16)
+	for _, l := range strings.Split(out, "\n") {
+		src, rest, ok := strings.Cut(l, " on ")
+		if !ok {
+			continue
+		}
+		var opts string
+		if i := strings.LastIndex(rest, " ("); i > -1 && strings.HasSuffix(rest, ")") {
+			rest, opts = rest[:i], rest[i+2:len(rest)-1]
+		}
+		mnt := Mount{Source: src, Path: rest}
+		if p, t, ok := strings.Cut(rest, " type "); ok {
+			mnt.Path, mnt.Type = p, t
+		}
+		for _, o := range strings.Split(opts, ",") {
+			o = strings.TrimSpace(o)
+			switch {
+			case o == "":
+			case mnt.Type == "":
+				mnt.Type = o
+			case o == "read-only":
+				mnt.Options = append(mnt.Options, "ro")
+			default:
+				mnt.Options = append(mnt.Options, o)
+			}
+		}
+		m = append(m, mnt)
+	}
+	return m
+}
+
+// Mount paths in /proc escape space, tab, newline, and backslash as octal
+// (e.g. "\040").
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+//go:build linux || freebsd || darwin
+
+package os2
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// DataSize gets the number of bytes in the file that aren't holes, using
+// SEEK_DATA and SEEK_HOLE.
+func DataSize(path string) (int64, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+
+	var (
+		fd    = int(fp.Fd())
+		total int64
+		off   int64
+	)
+	for {
+		data, err := unix.Seek(fd, off, unix.SEEK_DATA)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) { // No more data after off.
+				return total, nil
+			}
+			return 0, err
+		}
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			return 0, err
+		}
+		total += hole - data
+		off = hole
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !freebsd && !darwin
+
+package os2
+
+import (
+	"errors"
+	"runtime"
+)
+
+func DataSize(path string) (int64, error) {
+	return 0, errors.New("SEEK_DATA not supported on " + runtime.GOOS)
+}
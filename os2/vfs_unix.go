@@ -3,21 +3,17 @@
 package os2
 
 import (
-	"bufio"
 	"errors"
 	"io/fs"
 	"os"
 	"os/exec"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 
 	"zgo.at/zli"
 )
 
-// TODO: unused at the moment; ls -l prints this, but I don't think I've ever
-// used it in 25 years. Should maybe add option though.
 func Numlinks(absdir string, fi fs.FileInfo) uint64 {
 	if fi.Sys() == nil {
 		return 0
@@ -40,6 +36,28 @@ func Serial(absdir string, fi fs.FileInfo) uint64 {
 	return fi.Sys().(*syscall.Stat_t).Ino
 }
 
+// Device ID of the filesystem the file resides on.
+func Device(fi fs.FileInfo) uint64 {
+	if fi.Sys() == nil {
+		return 0
+	}
+	return uint64(fi.Sys().(*syscall.Stat_t).Dev)
+}
+
+// Hardlink gets a key to identify files with more than one link; ok is false if
+// the file has just one link (or if we don't know). Directories are never
+// reported.
+func Hardlink(fi fs.FileInfo) (key [2]uint64, ok bool) {
+	if fi.Sys() == nil || fi.IsDir() {
+		return key, false
+	}
+	s := fi.Sys().(*syscall.Stat_t)
+	if s.Nlink <= 1 {
+		return key, false
+	}
+	return [2]uint64{uint64(s.Dev), uint64(s.Ino)}, true
+}
+
 func Blocks(fi fs.FileInfo) int64 {
 	if fi.Sys() == nil {
 		return -1
@@ -56,93 +74,74 @@ func IsELOOP(err error) bool {
 }
 
 var (
-	mnts     []string
-	blocks   []int
-	mntsOnce sync.Once
+	blocks   []int // Block size for every entry in mnts; 0 if not read yet.
+	blocksMu sync.Mutex
 )
 
-// Linux and illumos.
-func mntsProc() bool {
-	var fp *os.File
+func readMounts() []Mount {
+	// Linux; /proc/mounts is for older systems or if /proc/self isn't
+	// available for some reason.
+	if fp, err := os.Open("/proc/self/mountinfo"); err == nil {
+		defer fp.Close()
+		if m, err := ParseMountinfo(fp); err == nil && len(m) > 0 {
+			return m
+		}
+	}
 	for _, f := range []string{
 		"/proc/mounts", // Linux
 		"/etc/mnttab",  // illumos
 	} {
-		var err error
-		fp, err = os.Open(f)
-		if err == nil {
-			break
-		}
-	}
-	if fp == nil {
-		return false
-	}
-	defer fp.Close()
-
-	scan := bufio.NewScanner(fp)
-	mnts = make([]string, 0, 4)
-	for scan.Scan() {
-		l := strings.Fields(scan.Text())
-		if l[0] != "cgroup" && l[0] != "cgroup2" {
-			mnts = append(mnts, l[1])
+		if fp, err := os.Open(f); err == nil {
+			defer fp.Close()
+			if m, err := ParseMounts(fp); err == nil && len(m) > 0 {
+				return m
+			}
 		}
 	}
-	return true
-}
 
-// Other Unix. TODO: there's probably a better way.
-func mntsCmd() bool {
+	// Other Unix. TODO: there's probably a better way.
 	out, err := exec.Command("mount").CombinedOutput()
 	if err != nil {
-		return false
+		return nil
 	}
-	for _, l := range strings.Split(string(out), "\n") {
-		f := strings.Fields(l)
-		if len(f) >= 3 && f[0] != "cgroup" && f[0] != "cgroup2" && f[0] != "map" {
-			mnts = append(mnts, f[2])
-		}
-	}
-	return true
+	return ParseMountCmd(string(out))
 }
 
 // Get the block size for the filesystem on which path resides.
 func Blocksize(path string) int {
-	mntsOnce.Do(func() {
-		if !mntsProc() && !mntsCmd() {
-			return
-		}
-
-		blocks = make([]int, 0, len(mnts))
-		for _, m := range mnts {
-			bsize, err := statfs(m)
-			if err != nil {
-				switch err {
-				case syscall.EPERM, syscall.EACCES:
-					// Ignore permission errors. If we can't read this, then we
-					// won't be able to read anything else either, so it's okay.
-				default:
-					zli.Errorf("statfs %q: %s", m, err)
-				}
-				bsize = 512
-			}
+	i := mountIndex(path)
+	if i == -1 {
+		// This should never happen, but print warning just in case.
+		zli.Errorf("blocksize: no blocksize found for %q; defaulting to 512", path)
+		return 512
+	}
 
-			// statvfs also has f_frsize, for "Fundamental file system block
-			// size", but I'm not really sure when/why to use this over f_bsize.
-			// FreeBSD manpage has "minimum unit of allocation on this file
-			// system. (This corresponds to the f_bsize member of struct
-			// statfs)", so it's always the same? POSIX doesn't say much either.
-			// So idk.
-			blocks = append(blocks, bsize)
-		}
-	})
+	blocksMu.Lock()
+	defer blocksMu.Unlock()
+	if blocks == nil {
+		blocks = make([]int, len(mnts))
+	}
+	if blocks[i] > 0 {
+		return blocks[i]
+	}
 
-	for i, m := range mnts {
-		if strings.HasPrefix(path, m) {
-			return blocks[i]
+	bsize, err := statfs(mnts[i].Path)
+	if err != nil {
+		switch err {
+		case syscall.EPERM, syscall.EACCES:
+			// Ignore permission errors. If we can't read this, then we
+			// won't be able to read anything else either, so it's okay.
+		default:
+			zli.Errorf("statfs %q: %s", mnts[i].Path, err)
 		}
+		bsize = 512
 	}
 
-	// This should never happen, but print warning just in case.
-	zli.Errorf("blocksize: no blocksize found for %q; defaulting to 512", path)
-	return 512
+	// statvfs also has f_frsize, for "Fundamental file system block size", but
+	// I'm not really sure when/why to use this over f_bsize. FreeBSD manpage
+	// has "minimum unit of allocation on this file system. (This corresponds
+	// to the f_bsize member of struct statfs)", so it's always the same? POSIX
+	// doesn't say much either. So idk.
+	blocks[i] = bsize
+	return bsize
 }
@@ -11,7 +11,7 @@ import (
 	"zgo.at/zli"
 )
 
-func Numlinks(absdir string, fi fs.FileInfo) int {
+func Numlinks(absdir string, fi fs.FileInfo) uint64 {
 	fp, err := os.Open(filepath.Join(absdir, fi.Name()))
 	if err != nil {
 		return 1
@@ -24,7 +24,7 @@ func Numlinks(absdir string, fi fs.FileInfo) int {
 		zli.Errorf(err)
 		return 1
 	}
-	return int(info.NumberOfLinks)
+	return uint64(info.NumberOfLinks)
 }
 
 func OwnerID(absdir string, fi fs.FileInfo) (string, string) {
@@ -72,6 +72,15 @@ func Blocks(fi fs.FileInfo) int64 {
 	return fi.Size() / 512
 }
 
+// TODO: would need the volume serial number, which requires opening the file.
+func Device(fi fs.FileInfo) uint64 { return 0 }
+
+// TODO: need to open every file to get the number of links; too slow.
+func Hardlink(fi fs.FileInfo) ([2]uint64, bool) { return [2]uint64{}, false }
+
+// TODO: could use GetVolumePathName() and GetVolumeInformation().
+func readMounts() []Mount { return nil }
+
 func Blocksize(path string) int {
 	// TODO: not sure how to get this.
 	return 512
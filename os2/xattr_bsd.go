@@ -0,0 +1,73 @@
+//go:build freebsd || netbsd
+
+package os2
+
+import (
+	"errors"
+	"slices"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListXattrs lists the names of all extended attributes for path, without
+// following symlinks, sorted by name. Filesystems that don't support xattrs
+// return nil.
+//
+// Names are prefixed with the namespace ("user." or "system."), like on Linux.
+func ListXattrs(path string) ([]string, error) {
+	var names []string
+	for _, ns := range []struct {
+		id     int
+		prefix string
+	}{
+		{unix.EXTATTR_NAMESPACE_USER, "user."},
+		{unix.EXTATTR_NAMESPACE_SYSTEM, "system."},
+	} {
+		sz, err := unix.ExtattrListLink(path, ns.id, 0, 0)
+		if err != nil {
+			// Reading the system namespace requires root.
+			if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EOPNOTSUPP) {
+				continue
+			}
+			return nil, err
+		}
+		if sz == 0 {
+			continue
+		}
+		buf := make([]byte, sz)
+		sz, err = unix.ExtattrListLink(path, ns.id, uintptr(unsafe.Pointer(&buf[0])), len(buf))
+		if err != nil {
+			return nil, err
+		}
+		// Every name is prefixed with a length byte.
+		buf = buf[:sz]
+		for len(buf) > 0 {
+			l := int(buf[0])
+			if l+1 > len(buf) {
+				break
+			}
+			names = append(names, ns.prefix+string(buf[1:l+1]))
+			buf = buf[l+1:]
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// GetXattr gets the value of the extended attribute name, without following
+// symlinks. It returns nil if the attribute doesn't exist.
+func GetXattr(path, name string) ([]byte, error) {
+	sz, err := unix.Lgetxattr(path, name, nil)
+	if err == nil && sz > 0 {
+		buf := make([]byte, sz)
+		sz, err = unix.Lgetxattr(path, name, buf)
+		if err == nil {
+			return buf[:sz], nil
+		}
+	}
+	if errors.Is(err, unix.ENOATTR) || errors.Is(err, unix.EOPNOTSUPP) {
+		return nil, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,5 @@
+package os2
+
+import "golang.org/x/sys/unix"
+
+const enoattr = unix.ENOATTR
@@ -0,0 +1,6 @@
+package os2
+
+import "golang.org/x/sys/unix"
+
+// Linux uses ENODATA for attributes that don't exist.
+const enoattr = unix.ENODATA
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package os2
+
+// TODO: Windows has alternate data streams, and illumos has extended
+// attributes as files in a hidden directory (see fsattr(5)).
+
+func ListXattrs(path string) ([]string, error)   { return nil, nil }
+func GetXattr(path, name string) ([]byte, error) { return nil, nil }
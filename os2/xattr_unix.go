@@ -0,0 +1,59 @@
+//go:build linux || darwin
+
+package os2
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListXattrs lists the names of all extended attributes for path, without
+// following symlinks, sorted by name. Filesystems that don't support xattrs
+// return nil.
+func ListXattrs(path string) ([]string, error) {
+	buf, err := getBuf(func(b []byte) (int, error) { return unix.Llistxattr(path, b) })
+	if err != nil || len(buf) == 0 {
+		return nil, err
+	}
+	var names []string
+	for _, n := range bytes.Split(bytes.TrimRight(buf, "\x00"), []byte{0}) {
+		names = append(names, string(n))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// GetXattr gets the value of the extended attribute name, without following
+// symlinks. It returns nil if the attribute doesn't exist.
+func GetXattr(path, name string) ([]byte, error) {
+	return getBuf(func(b []byte) (int, error) { return unix.Lgetxattr(path, name, b) })
+}
+
+// Call f with a buffer large enough to hold the value; the size may change
+// between calls, so retry on ERANGE.
+func getBuf(f func([]byte) (int, error)) ([]byte, error) {
+	for range 8 {
+		sz, err := f(nil)
+		if err == nil && sz > 0 {
+			buf := make([]byte, sz)
+			sz, err = f(buf)
+			if err == nil {
+				return buf[:sz], nil
+			}
+		}
+		switch {
+		case err == nil:
+			return nil, nil
+		case errors.Is(err, unix.ERANGE):
+			continue
+		case errors.Is(err, unix.ENOTSUP), errors.Is(err, enoattr):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return nil, unix.ERANGE
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -11,11 +11,13 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"zgo.at/elles/os2"
 	"zgo.at/zli"
@@ -36,57 +38,136 @@ type (
 	}
 	cols struct {
 		longest []int
+		header  []col
 		rows    [][]col
+		below   [][]string // Extra lines to print below a row, for -@.
 	}
 	opts struct {
-		list, quote, fullTime, maxColWidth, minCols int
-		dirSlash, classify, comma                   bool
-		numericUID, group, hyperlink                bool
-		blockSize, timeField                        string
-		one, cols, recurse, inode                   bool
-		trim, octal, derefAll                       bool
+		list, fullTime, maxColWidth, minCols int
+		quote                                quoting
+		dirSlash, classify                   bool
+		numericUID, group, hyperlink         bool
+		timeField, groupBy                   string
+		size                                 sizeFormat
+		digits                               *numFormat // nil if -, isn't given.
+		one, cols, recurse, inode            bool
+		trim, octal, derefAll                bool
+		header, summary, count, countSplit   bool
+		bars, barsTotal                      bool
+		xattr                                int
+		context, acl, caps, flags            bool
+		links, hardlinks, access             bool
+		fstype, mounts, suspicious           bool
+		dired                                bool // ls-compatible -ll for Emacs dired.
+		raw                                  bool // Machine-readable values for -format.
+		barScale                             int64
 	}
 )
 
 func getCols(p printable, opt opts) cols {
-	ncols := 1
-	if opt.list == 1 {
-		ncols = 3
-	} else if opt.list >= 2 {
-		ncols = 6
-	}
-	if opt.inode {
-		ncols++
-	}
-	cc := cols{
-		longest: make([]int, ncols),
-		rows:    make([][]col, 0, len(p.fi)),
-	}
+	cc := cols{rows: make([][]col, 0, len(p.fi))}
+	realdirs := make(map[string]string)
 
 	for _, fi := range p.fi {
 		fp, afp := p.dir, p.absdir
 		if p.isFiles {
 			fp, afp = fi.filepath, fi.filepathAbs
 		}
-		cur := make([]col, 0, ncols)
+		cur := make([]col, 0, 8)
+		var xattrs []string
+		if opt.list >= 2 || (opt.list > 0 && (opt.xattr > 0 || opt.acl)) {
+			xattrs, _ = os2.ListXattrs(p.abs(fi))
+		}
+		var (
+			mnt            os2.Mount
+			haveMnt, isMnt bool
+		)
+		if opt.fstype || opt.mounts {
+			path := realPath(realdirs, p.abs(fi))
+			mnt, haveMnt = os2.MountFor(path)
+			isMnt = haveMnt && mnt.Path == path && fi.Mode()&fs.ModeSymlink == 0
+		}
+		// Add a column; the header is only recorded for the first row, as every
+		// row has the same layout.
+		add := func(hdr string, c col) {
+			cur = append(cur, c)
+			if opt.header && len(cc.rows) == 0 {
+				cc.header = append(cc.header, col{s: hdr, w: len([]rune(hdr)), prop: c.prop})
+			}
+		}
 		if opt.list == 0 {
 			if opt.inode {
-				n := strconv.FormatUint(os2.Serial(p.absdir, fi), 10)
-				cur = append(cur, col{s: n, w: len(n)})
+				n := opt.digits.group(strconv.FormatUint(os2.Serial(p.absdir, fi), 10))
+				add("Inode", col{s: n, w: len(n)})
+			}
+			if opt.links {
+				l := nlink(p.absdir, fi, opt)
+				add("Links", col{s: l, w: len(l)})
+			}
+			if opt.access {
+				add("Access", col{s: fmtAccess(p.abs(fi), fi), w: 3, prop: alignLeft})
+			}
+
+			if opt.count {
+				c := fmtCount(fi, opt)
+				add("Entries", col{s: c, w: len(c)})
+			}
+			if opt.bars {
+				add("", col{s: bar(fi.Size(), opt.barScale), w: barWidth, prop: alignLeft})
+			}
+			if opt.context {
+				c := secContext(p.abs(fi))
+				add("Context", col{s: c, w: len(c), prop: alignLeft})
+			}
+			if opt.caps {
+				c := capabilities(p.abs(fi))
+				add("Capabilities", col{s: c, w: len(c), prop: alignLeft})
+			}
+			if opt.fstype {
+				t := fmtFSType(mnt, haveMnt)
+				add("Filesystem", col{s: t, w: len(t), prop: alignLeft})
+			}
+			if opt.mounts {
+				m := fmtMount(mnt, isMnt)
+				add("Mount", col{s: m, w: len(m), prop: alignLeft})
+			}
+			if opt.hardlinks {
+				h := fmtHardlink(fi)
+				add("Hardlink", col{s: h, w: utf8.RuneCountInString(h), prop: alignLeft})
+			}
+			if opt.suspicious {
+				d := strings.Join(deceptive(fi.Name()), ",")
+				add("Suspicious", col{s: d, w: len(d), prop: alignLeft})
 			}
 
 			n, w := decoratePath(fp, afp, fi, opt, false, !p.isFiles)
-			cur = append(cur, col{s: n, w: w, prop: alignNone})
+			add("Name", col{s: n, w: w, prop: alignNone})
 		} else if opt.list == 1 {
-			s, w := listSize(fi, p.absdir, opt.blockSize, opt.comma)
+			s, w := listSize(fi, p.absdir, opt)
 
 			if opt.inode {
-				n := strconv.FormatUint(os2.Serial(p.absdir, fi), 10)
-				cur = append(cur, col{s: n, w: len(n)})
-				cur = append(cur, col{s: s, w: w, prop: borderToLeft})
+				n := opt.digits.group(strconv.FormatUint(os2.Serial(p.absdir, fi), 10))
+				add("Inode", col{s: n, w: len(n)})
+				add("Size", col{s: s, w: w, prop: borderToLeft})
+			} else if opt.raw {
+				add("Size", col{s: s, w: w})
 			} else {
 				w++
-				cur = append(cur, col{s: " " + s, w: w})
+				add(" Size", col{s: " " + s, w: w})
+			}
+			if opt.bars {
+				add("", col{s: bar(fi.Size(), opt.barScale), w: barWidth, prop: alignLeft})
+			}
+			if opt.count {
+				c := fmtCount(fi, opt)
+				add("Entries", col{s: c, w: len(c), prop: borderToLeft})
+			}
+			if opt.links {
+				l := nlink(p.absdir, fi, opt)
+				add("Links", col{s: l, w: len(l), prop: borderToLeft})
+			}
+			if opt.access {
+				add("Access", col{s: fmtAccess(p.abs(fi), fi), w: 3, prop: borderToLeft | alignLeft})
 			}
 
 			var (
@@ -94,6 +175,10 @@ func getCols(p printable, opt opts) cols {
 				tt = getTime(p.absdir, fi, opt.timeField)
 			)
 			switch {
+			case opt.raw && tt.IsZero():
+				t = ""
+			case opt.raw:
+				t = tt.Format(time.RFC3339)
 			case tt.IsZero():
 				t = "????-??-??"
 			case opt.fullTime == 0:
@@ -103,14 +188,42 @@ func getCols(p printable, opt opts) cols {
 			default:
 				t = tt.Format("2006-01-02 15:04:05.000000000 -07:00")
 			}
-			cur = append(cur, col{s: t, w: len(t), prop: borderToLeft})
+			add(timeHeader(opt.timeField), col{s: t, w: len(t), prop: borderToLeft})
+			if opt.context {
+				c := secContext(p.abs(fi))
+				add("Context", col{s: c, w: len(c), prop: borderToLeft | alignLeft})
+			}
+			if opt.caps {
+				c := capabilities(p.abs(fi))
+				add("Capabilities", col{s: c, w: len(c), prop: borderToLeft | alignLeft})
+			}
+			if opt.fstype {
+				t := fmtFSType(mnt, haveMnt)
+				add("Filesystem", col{s: t, w: len(t), prop: borderToLeft | alignLeft})
+			}
+			if opt.mounts {
+				m := fmtMount(mnt, isMnt)
+				add("Mount", col{s: m, w: len(m), prop: borderToLeft | alignLeft})
+			}
+			if opt.hardlinks {
+				h := fmtHardlink(fi)
+				add("Hardlink", col{s: h, w: utf8.RuneCountInString(h), prop: borderToLeft | alignLeft})
+			}
+			if opt.suspicious {
+				d := strings.Join(deceptive(fi.Name()), ",")
+				add("Suspicious", col{s: d, w: len(d), prop: borderToLeft | alignLeft})
+			}
 
 			n, w := decoratePath(fp, afp, fi, opt, true, !p.isFiles)
-			cur = append(cur, col{s: n, w: w, prop: borderToLeft | alignNone})
+			add("Name", col{s: n, w: w, prop: borderToLeft | alignNone})
+			if opt.raw {
+				l := linkTarget(p.abs(fi), fi)
+				add("Target", col{s: l, w: len(l)})
+			}
 		} else {
 			if opt.inode {
-				n := strconv.FormatUint(os2.Serial(p.absdir, fi), 10)
-				cur = append(cur, col{s: n, w: len(n)})
+				n := opt.digits.group(strconv.FormatUint(os2.Serial(p.absdir, fi), 10))
+				add("Inode", col{s: n, w: len(n)})
 			}
 			var perm string
 			if opt.octal {
@@ -128,28 +241,60 @@ func getCols(p printable, opt opts) cols {
 			} else {
 				perm = strmode(fi.Mode())
 			}
-			cur = append(cur, col{s: perm, w: len(perm)})
+			perm += modeMarker(p.abs(fi), xattrs)
+			add("Mode", col{s: perm, w: len(perm), prop: alignLeft})
+			if opt.links || opt.dired {
+				l := nlink(p.absdir, fi, opt)
+				add("Links", col{s: l, w: len(l)})
+			}
+			if opt.access {
+				add("Access", col{s: fmtAccess(p.abs(fi), fi), w: 3, prop: alignLeft})
+			}
 
 			user, group := owner(p.absdir, fi, opt.numericUID)
-			cur = append(cur, col{s: user, w: len(user), prop: alignLeft})
-			if opt.group {
-				cur = append(cur, col{s: group, w: len(group), prop: alignLeft})
+			add("User", col{s: user, w: len(user), prop: alignLeft})
+			if opt.group || opt.raw || opt.dired {
+				add("Group", col{s: group, w: len(group), prop: alignLeft})
 			} else if user != group {
-				cur = append(cur, col{s: ":" + group, w: len(group) + 1, prop: alignLeft})
+				add("Group", col{s: ":" + group, w: len(group) + 1, prop: alignLeft})
 			} else {
-				cur = append(cur, col{})
+				add("Group", col{})
+			}
+			if opt.context {
+				c := secContext(p.abs(fi))
+				add("Context", col{s: c, w: len(c), prop: alignLeft})
+			}
+			if opt.flags {
+				fl, err := os2.FileFlags(p.abs(fi), fi)
+				if err != nil {
+					fl = "?"
+				}
+				add("Flags", col{s: fl, w: len(fl), prop: alignLeft})
 			}
 
-			s, w := listSize(fi, p.absdir, opt.blockSize, opt.comma)
-			cur = append(cur, col{s: s, w: w})
+			s, w := listSize(fi, p.absdir, opt)
+			add("Size", col{s: s, w: w})
+			if opt.bars {
+				add("", col{s: bar(fi.Size(), opt.barScale), w: barWidth, prop: alignLeft})
+			}
+			if opt.count {
+				c := fmtCount(fi, opt)
+				add("Entries", col{s: c, w: len(c)})
+			}
 
 			var (
 				t  string
 				tt = getTime(p.absdir, fi, opt.timeField)
 			)
 			switch {
+			case opt.raw && tt.IsZero():
+				t = ""
+			case opt.raw:
+				t = tt.Format(time.RFC3339)
 			case tt.IsZero():
 				t = "????-??-??"
+			case opt.fullTime == 0 && opt.dired:
+				t = lsTime(tt)
 			case opt.fullTime == 0:
 				t = tt.Format("Jan _2 15:04")
 			case opt.fullTime == 1:
@@ -157,31 +302,437 @@ func getCols(p printable, opt opts) cols {
 			default:
 				t = tt.Format("2006-01-02 15:04:05.000000000 -07:00")
 			}
-			cur = append(cur, col{s: t, w: len(t)})
+			add(timeHeader(opt.timeField), col{s: t, w: len(t)})
+			if opt.caps {
+				c := capabilities(p.abs(fi))
+				add("Capabilities", col{s: c, w: len(c), prop: borderToLeft | alignLeft})
+			}
+			if opt.fstype {
+				t := fmtFSType(mnt, haveMnt)
+				add("Filesystem", col{s: t, w: len(t), prop: borderToLeft | alignLeft})
+			}
+			if opt.mounts {
+				m := fmtMount(mnt, isMnt)
+				add("Mount", col{s: m, w: len(m), prop: borderToLeft | alignLeft})
+			}
+			if opt.hardlinks {
+				h := fmtHardlink(fi)
+				add("Hardlink", col{s: h, w: utf8.RuneCountInString(h), prop: borderToLeft | alignLeft})
+			}
+			if opt.suspicious {
+				d := strings.Join(deceptive(fi.Name()), ",")
+				add("Suspicious", col{s: d, w: len(d), prop: borderToLeft | alignLeft})
+			}
 
-			n, w := decoratePath(fp, afp, fi, opt, true, !p.isFiles)
-			cur = append(cur, col{s: n, w: w, prop: borderToLeft | alignNone})
+			if opt.dired {
+				n := fi.Name()
+				if p.isFiles && fp != "" && !opt.recurse {
+					n = filepath.Join(fp, n)
+				}
+				n = diredQuote(n, opt)
+				add("Name", col{s: n, w: len(n), prop: alignNone})
+				// Always add it, as every row needs the same columns.
+				l := linkTarget(p.abs(fi), fi)
+				if l != "" {
+					l = "-> " + diredQuote(l, opt)
+				}
+				add("Target", col{s: l, w: len(l), prop: alignNone})
+			} else {
+				n, w := decoratePath(fp, afp, fi, opt, true, !p.isFiles)
+				add("Name", col{s: n, w: w, prop: borderToLeft | alignNone})
+			}
+			if opt.raw {
+				l := linkTarget(p.abs(fi), fi)
+				add("Target", col{s: l, w: len(l)})
+			}
 		}
 
 		cc.rows = append(cc.rows, cur)
-		var w int
-		for i := range ncols {
-			if cur[i].w > cc.longest[i] {
-				cc.longest[i] = cur[i].w
+		if opt.list > 0 && (opt.xattr > 0 || opt.acl) {
+			var l []string
+			if opt.xattr > 0 {
+				l = xattrLines(p.abs(fi), xattrs, opt.xattr > 1)
+			}
+			if opt.acl && (slices.Contains(xattrs, xattrACL) || slices.Contains(xattrs, xattrACLDefault)) {
+				l = append(l, aclLines(p.abs(fi))...)
 			}
-			w += cur[i].w
+			cc.below = append(cc.below, l)
+		}
+	}
+
+	if len(cc.rows) > 0 {
+		cc.longest = make([]int, len(cc.rows[0]))
+	}
+	for _, r := range cc.rows {
+		for i := range r {
+			if r[i].w > cc.longest[i] {
+				cc.longest[i] = r[i].w
+			}
+		}
+	}
+	for i := range cc.header {
+		if cc.header[i].w > cc.longest[i] {
+			cc.longest[i] = cc.header[i].w
 		}
 	}
 	return cc
 }
 
-func decoratePath(dir, absdir string, fi fs.FileInfo, opt opts, linkDest, listingDir bool) (string, int) {
+func nlink(absdir string, fi fileInfo, opt opts) string {
+	if fi.Size() == -1 {
+		return "?"
+	}
+	return opt.digits.group(strconv.FormatUint(os2.Numlinks(absdir, fi), 10))
+}
+
+// Get the symlink target for -format; this is blank for anything that's not a
+// symlink.
+func linkTarget(path string, fi fileInfo) string {
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		return ""
+	}
+	l, _ := os.Readlink(path)
+	return l
+}
+
+// Resolve symlinks in the directory of path, as the mount list always has the
+// real path. The result for every directory is cached in dirs.
+func realPath(dirs map[string]string, path string) string {
+	dir, name := filepath.Split(path)
+	real, ok := dirs[dir]
+	if !ok {
+		var err error
+		real, err = filepath.EvalSymlinks(dir)
+		if err != nil {
+			real = dir
+		}
+		dirs[dir] = real
+	}
+	return filepath.Join(real, name)
+}
+
+// Format the filesystem type for -fstype, followed by "ro" and "noexec" if the
+// filesystem is mounted with those options: "ext4" or "tmpfs (ro,noexec)".
+func fmtFSType(m os2.Mount, ok bool) string {
+	if !ok || m.Type == "" {
+		return "?"
+	}
+	var o []string
+	if m.ReadOnly() {
+		o = append(o, "ro")
+	}
+	if m.NoExec() {
+		o = append(o, "noexec")
+	}
+	if len(o) == 0 {
+		return m.Type
+	}
+	return m.Type + " (" + strings.Join(o, ",") + ")"
+}
+
+// Format the mount source for -mounts; this is blank for anything that's not
+// a mount point.
+func fmtMount(m os2.Mount, isMnt bool) string {
+	if !isMnt {
+		return ""
+	}
+	return m.Source
+}
+
+// Format the effective access for the current user as "rwx", with a "-" for
+// every permission that's missing.
+func fmtAccess(path string, fi fileInfo) string {
+	r, w, x := os2.Access(path, fi)
+	b := []byte("---")
+	if r {
+		b[0] = 'r'
+	}
+	if w {
+		b[1] = 'w'
+	}
+	if x {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// Format the hardlink set for -hardlinks as "#1", or "#1×3" if the other
+// entries are collapsed.
+func fmtHardlink(fi fileInfo) string {
+	if fi.hardlink == nil {
+		return ""
+	}
+	if fi.hardlink.collapsed {
+		return "#" + strconv.Itoa(fi.hardlink.n) + "×" + strconv.Itoa(fi.hardlink.count)
+	}
+	return "#" + strconv.Itoa(fi.hardlink.n)
+}
+
+// Format the number of entries for -count; this is blank for anything that's
+// not a directory.
+func fmtCount(fi fileInfo, opt opts) string {
+	if fi.count == nil {
+		return ""
+	}
+	f := func(n int) string {
+		s := strconv.Itoa(n)
+		s = opt.digits.group(s)
+		return s
+	}
+	if opt.countSplit {
+		return f(fi.count.dirs) + "d " + f(fi.count.files) + "f"
+	}
+	return f(fi.count.dirs + fi.count.files)
+}
+
+const (
+	xattrACL        = "system.posix_acl_access"
+	xattrACLDefault = "system.posix_acl_default"
+	xattrSELinux    = "security.selinux"
+	xattrCap        = "security.capability"
+)
+
+// Get the marker to display after the permissions in -ll: "+" if there's an
+// ACL, "@" for other extended attributes, and "." if there's only a security
+// context (e.g. SELinux).
+func modeMarker(path string, xattrs []string) string {
+	var ctx, other bool
+	for _, x := range xattrs {
+		switch x {
+		case xattrACL, xattrACLDefault:
+			access, def, err := os2.ACLs(path)
+			if err == nil && (access.Extended() || len(def) > 0) {
+				return "+"
+			}
+		case xattrSELinux:
+			ctx = true
+		default:
+			other = true
+		}
+	}
+	switch {
+	case other:
+		return "@"
+	case ctx:
+		return "."
+	}
+	return ""
+}
+
+// Get the security context for -Z, or "?" if there is none.
+func secContext(path string) string {
+	c, _ := os2.GetXattr(path, xattrSELinux)
+	if s := strings.TrimRight(string(c), "\x00"); s != "" {
+		return s
+	}
+	return "?"
+}
+
+// Get the file capabilities for -cap, e.g. "cap_net_raw=ep".
+func capabilities(path string) string {
+	c, err := os2.GetCapabilities(path)
+	if err != nil {
+		return "?"
+	}
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+// List ACL entries for -acl, like getfacl.
+func aclLines(path string) []string {
+	access, def, err := os2.ACLs(path)
+	if err != nil {
+		return []string{"    " + err.Error()}
+	}
+	var l []string
+	for _, a := range []struct {
+		prefix string
+		acl    os2.ACL
+	}{{"", access}, {"default:", def}} {
+		mask := uint8(7)
+		for _, e := range a.acl {
+			if e.Tag == os2.ACLMask {
+				mask = e.Perm
+			}
+		}
+		for _, e := range a.acl {
+			var q string
+			switch e.Tag {
+			case os2.ACLUser:
+				q = userName(strconv.FormatUint(uint64(e.ID), 10))
+			case os2.ACLGroup:
+				q = groupName(strconv.FormatUint(uint64(e.ID), 10))
+			}
+			line := "    " + a.prefix + e.Tag.String() + ":" + q + ":" + e.PermString()
+			switch e.Tag {
+			case os2.ACLUser, os2.ACLGroupObj, os2.ACLGroup:
+				if e.Perm&^mask != 0 {
+					line += "  #effective:" + os2.ACLEntry{Perm: e.Perm & mask}.PermString()
+				}
+			}
+			l = append(l, line)
+		}
+	}
+	return l
+}
+
+// List the name and size of extended attributes for -@, and the value for -@@.
+func xattrLines(path string, names []string, values bool) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	var (
+		sizes       = make([]string, len(names))
+		vals        = make([]string, len(names))
+		nameW, sizW int
+	)
+	for i, n := range names {
+		v, _ := os2.GetXattr(path, n)
+		sizes[i] = strconv.Itoa(len(v))
+		if values {
+			s, text := fmtXattr(v)
+			if text {
+				s = strconv.Quote(s)
+			}
+			vals[i] = "  " + s
+		}
+		nameW, sizW = max(nameW, len(doQuote(n, 0))), max(sizW, len(sizes[i]))
+	}
+	l := make([]string, 0, len(names))
+	for i, n := range names {
+		l = append(l, fmt.Sprintf("    %-*s  %*s%s", nameW, doQuote(n, 0), sizW, sizes[i], vals[i]))
+	}
+	return l
+}
+
+// Format an xattr value as text if it's printable UTF-8 (ignoring a trailing
+// NUL byte), or as base64 with a "0s" prefix if it's not, like getfattr. Text
+// that starts with "0s" is also written as base64, so it's not mistaken for an
+// encoded value.
+func fmtXattr(v []byte) (string, bool) {
+	s := strings.TrimSuffix(string(v), "\x00")
+	if !utf8.ValidString(s) || strings.HasPrefix(s, "0s") ||
+		strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) > -1 {
+		return "0s" + base64.StdEncoding.EncodeToString(v), false
+	}
+	return s, true
+}
+
+const barWidth = 10
+
+// Get the size that corresponds to a full bar for -bars: the largest entry, or
+// the total of all entries.
+func barScale(p printable, total bool) int64 {
+	var n int64
+	for _, fi := range p.fi {
+		sz := fi.Size()
+		if sz <= 0 {
+			continue
+		}
+		if total {
+			n += sz
+		} else if sz > n {
+			n = sz
+		}
+	}
+	return n
+}
+
+// Draw a bar proportional to sz/scale.
+func bar(sz, scale int64) string {
+	if sz <= 0 || scale <= 0 {
+		return strings.Repeat(" ", barWidth)
+	}
+	const eighths = " ▏▎▍▌▋▊▉"
+	var (
+		b     = make([]rune, 0, barWidth)
+		cells = float64(sz) / float64(scale) * barWidth
+		full  = int(cells)
+		part  = int((cells - float64(full)) * 8)
+	)
+	b = append(b, []rune(strings.Repeat("█", full))...)
+	if full < barWidth {
+		if full == 0 && part == 0 {
+			part = 1 // Always show something for non-empty files.
+		}
+		b = append(b, []rune(eighths)[part])
+	}
+	for len(b) < barWidth {
+		b = append(b, ' ')
+	}
+	return string(b)
+}
+
+func timeHeader(timeField string) string {
+	switch timeField {
+	case "btime":
+		return "Created"
+	case "atime":
+		return "Accessed"
+	default:
+		return "Modified"
+	}
+}
+
+// Get the LS_COLORS key for the type of fi, e.g. "di" for directories or "ex"
+// for executables; this is also used for the CSS classes with -format=html.
+// Capabilities ("ca") and multiple hardlinks ("mh") need an extra syscall, and
+// are only checked if caps or multi is set.
+func typeClass(absdir string, fi fileInfo, caps, multi bool) string {
+	m := fi.Mode()
+	switch {
+	case m&fs.ModeSetuid != 0:
+		return "su"
+	case m&fs.ModeSetgid != 0:
+		return "sg"
+	case caps && m.IsRegular() && capabilities(filepath.Join(absdir, fi.Name())) != "":
+		return "ca"
+	case m.IsRegular() && m&0o111 != 0:
+		return "ex"
+	case multi && m.IsRegular() && os2.Numlinks(absdir, fi) > 1:
+		return "mh"
+	case m.IsRegular():
+		return "fi"
+	case m.IsDir():
+		switch {
+		case m&0o002 != 0 && m&fs.ModeSticky != 0:
+			return "tw"
+		case m&fs.ModeSticky != 0:
+			return "st"
+		case m&0o002 != 0:
+			return "ow"
+		}
+		return "di"
+	case m&fs.ModeNamedPipe != 0:
+		return "pi"
+	case m&fs.ModeSocket != 0:
+		return "so"
+	case m&fs.ModeCharDevice != 0: // Also has ModeDevice set.
+		return "cd"
+	case m&fs.ModeDevice != 0:
+		return "bd"
+	case os2.IsDoor(fi):
+		return "do"
+	case m&fs.ModeSymlink != 0:
+		return "ln"
+	}
+	return ""
+}
+
+func decoratePath(dir, absdir string, fi fileInfo, opt opts, linkDest, listingDir bool) (string, int) {
 	n := fi.Name()
 	if dir != "" && !opt.recurse && !listingDir {
 		n = filepath.Join(dir, n)
 	}
-	n = doQuote(n, opt.quote)
+	if opt.raw {
+		return n, len(n)
+	}
 	hidden := n[0] == '.'
+	suspicious := colorSuspicious != "" && deceptive(fi.Name()) != nil
+	n = opt.quote.quote(n)
+	sparse := isSparse(fi)
 
 	// TODO: this should probably use zgo.at/termtext or something, pretty
 	// sure alignment of this will be off in cases of double-width stuff
@@ -196,6 +747,12 @@ func decoratePath(dir, absdir string, fi fs.FileInfo, opt opts, linkDest, listin
 			if hidden {
 				c += colorHidden
 			}
+			if sparse {
+				c += colorSparse
+			}
+			if suspicious {
+				c += colorSuspicious
+			}
 			n = c + n + reset
 		}
 		if len(class) > 0 && (opt.classify || (class[0] == "/" && opt.dirSlash)) {
@@ -207,41 +764,40 @@ func decoratePath(dir, absdir string, fi fs.FileInfo, opt opts, linkDest, listin
 	if fi.Mode()&0o111 != 0 {
 		ex = "*"
 	}
-	switch {
-	case fi.Mode()&fs.ModeSetuid != 0:
+	switch typeClass(absdir, fi, colorCap != "", colorMultiHardlink != "") {
+	case "su":
 		ifset(colorSuid, ex)
-	case fi.Mode()&fs.ModeSetgid != 0:
+	case "sg":
 		ifset(colorSgid, ex)
-	case fi.Mode().IsRegular():
-		if ex != "" {
-			ifset(colorExec, ex)
-		} else {
-			ifset(colorFile)
-		}
-	case fi.IsDir():
-		switch {
-		case fi.Mode()&0o002 != 0 && fi.Mode()&fs.ModeSticky != 0:
-			ifset(colorOtherWriteStick, "/")
-		case fi.Mode()&fs.ModeSticky != 0:
-			ifset(colorSticky, "/")
-		case fi.Mode()&0o002 != 0:
-			ifset(colorOtherWrite, "/")
-		default:
-			ifset(colorDir, "/")
-		}
-	case fi.Mode()&fs.ModeNamedPipe != 0:
+	case "ca":
+		ifset(colorCap, ex)
+	case "ex":
+		ifset(colorExec, ex)
+	case "mh":
+		ifset(colorMultiHardlink)
+	case "fi":
+		ifset(colorFile)
+	case "tw":
+		ifset(colorOtherWriteStick, "/")
+	case "st":
+		ifset(colorSticky, "/")
+	case "ow":
+		ifset(colorOtherWrite, "/")
+	case "di":
+		ifset(colorDir, "/")
+	case "pi":
 		ifset(colorPipe, "|")
-	case fi.Mode()&fs.ModeSocket != 0:
+	case "so":
 		ifset(colorSocket, "=")
-	case fi.Mode()&fs.ModeDevice != 0:
+	case "bd":
 		ifset(colorBlockDev)
-	case fi.Mode()&fs.ModeCharDevice != 0:
+	case "cd":
 		ifset(colorCharDev)
-	case os2.IsDoor(fi):
+	case "do":
 		ifset(colorDoor, ">")
 
 	// Symlink
-	case fi.Mode()&fs.ModeSymlink != 0:
+	case "ln":
 		if opt.derefAll {
 			// -L and unresolvable symlinks: since resolving it fails earlier on
 			// it's still a link here, but we don't really want to display it as
@@ -280,7 +836,7 @@ func decoratePath(dir, absdir string, fi fs.FileInfo, opt opts, linkDest, listin
 					}
 				}
 
-				l = doQuote(l, opt.quote)
+				l = opt.quote.quote(l)
 				n = c + n + reset + " → " + targetC + l + targetR
 				width += 3 + len(l)
 			}
@@ -292,6 +848,13 @@ func decoratePath(dir, absdir string, fi fs.FileInfo, opt opts, linkDest, listin
 	if hidden {
 		ifset(colorHidden)
 	}
+	if sparse && !didColor {
+		ifset(colorSparse)
+	}
+	if suspicious && !didColor {
+		suspicious = false // Don't add it twice.
+		ifset(colorSuspicious)
+	}
 
 	if opt.hyperlink {
 		hostnameOnce.Do(func() { h, _ := os.Hostname(); hostname = esc(h) })
@@ -320,16 +883,22 @@ func isVariationSelector(r rune) bool {
 	return (r >= 0x180b && r <= 0x180f) || (r >= 0xfe00 && r <= 0xfe0f) || (r >= 0xe0100 && r <= 0xe01ef)
 }
 
+// Quote in for display; unprintable characters are escaped. Bytes that aren't
+// valid UTF-8 are escaped as \xNN, so the name still identifies the file (as
+// opposed to U+FFFD). Other unprintable characters are escaped as \uNNNN or
+// \UNNNNNNNN, except for \e, \n, \r, \t, and ASCII control characters.
 func doQuote(in string, level int) string {
 	var (
 		buf      = new(strings.Builder)
 		dblQuote = level == 2
-		n        = []rune(in)
 	)
-	buf.Grow(len(n))
-	for i, r := range n {
+	buf.Grow(len(in))
+	for i := 0; i < len(in); {
+		r, size := utf8.DecodeRuneInString(in[i:])
+		invalid := r == utf8.RuneError && size == 1
+
 		//if !unicode.IsPrint(r) || unicode.Is(unicode.Mn, r) {
-		if !unicode.IsPrint(r) || isVariationSelector(r) {
+		if isUnprintable(r, size) {
 			// Only display the brief escapes for \e, \n, \r, and \t as these
 			// are fairly well-known. Who even knows what \v is?
 			if level == 0 {
@@ -337,23 +906,23 @@ func doQuote(in string, level int) string {
 			} else if level == 1 {
 				dblQuote = true
 			}
-			switch r {
-			case 0x1b:
+			switch {
+			case invalid:
+				fmt.Fprintf(buf, "\\x%02x", in[i])
+			case r == 0x1b:
 				buf.WriteString(`\e`)
-			case '\n':
+			case r == '\n':
 				buf.WriteString(`\n`)
-			case '\r':
+			case r == '\r':
 				buf.WriteString(`\r`)
-			case '\t':
+			case r == '\t':
 				buf.WriteString(`\t`)
+			case r < 0x80:
+				fmt.Fprintf(buf, "\\x%02x", r)
+			case r < 0xffff:
+				fmt.Fprintf(buf, "\\u%04x", r)
 			default:
-				if r < 0xff {
-					fmt.Fprintf(buf, "\\x%02x", r)
-				} else if r < 0xffff {
-					fmt.Fprintf(buf, "\\u%04x", r)
-				} else {
-					fmt.Fprintf(buf, "\\U%08x", r)
-				}
+				fmt.Fprintf(buf, "\\U%08x", r)
 			}
 			if level == 0 {
 				buf.WriteString("'")
@@ -361,7 +930,7 @@ func doQuote(in string, level int) string {
 		} else {
 			// Always quote paths with leading and trailing spaces; hugely
 			// confusing otherwise.
-			if r == ' ' && (i == 0 || i == len(n)-1) {
+			if r == ' ' && (i == 0 || i+size == len(in)) {
 				dblQuote = true
 			}
 			if level == 1 && needQuote(r) {
@@ -372,6 +941,7 @@ func doQuote(in string, level int) string {
 			}
 			buf.WriteRune(r)
 		}
+		i += size
 	}
 	if dblQuote {
 		buf.WriteByte('"')
@@ -407,108 +977,291 @@ func shortSize(n float64, u string) string {
 	return fmt.Sprintf("%.1f"+u, n)
 }
 
-func groupDigits(s string) string {
-	i, frac, hasFrac := strings.Cut(s, ".")
-	if hasFrac {
-		frac = "." + frac
+func listSize(fi fileInfo, absdir string, opt opts) (string, int) {
+	if opt.raw {
+		if fi.Size() == -1 {
+			return "", 0
+		}
+		s := strconv.FormatInt(fi.Size(), 10)
+		return s, len(s)
 	}
-	if strings.HasSuffix(s, "K") || strings.HasSuffix(s, "M") || strings.HasSuffix(s, "G") || strings.HasSuffix(s, "T") {
-		frac += i[len(i)-1:]
-		hasFrac = true
-		i = i[:len(i)-1]
+	if fi.Size() == -1 {
+		return "???", 3
 	}
-	if len(i) <= 3 {
-		return s
+	switch {
+	case opt.size.blocks == 's':
+		s := strconv.FormatInt(fi.Blocks(), 10)
+		s = opt.digits.group(s)
+		return s, len(s)
+	case opt.size.blocks == 'S':
+		bs := os2.Blocksize(filepath.Join(absdir, fi.Name()))
+		s := strconv.FormatFloat(math.Ceil(float64(fi.Size())/float64(bs)), 'f', 0, 64)
+		s = opt.digits.group(s)
+		return s, len(s)
+	case opt.size.unit > 0 && opt.size.suffix == "":
+		s := fmtSize(fi.Size(), opt.size, opt.digits)
+		return s, len(s)
+	default:
+		// Mark sparse files, and also show the allocated size with -ll.
+		s := fmtSize(fi.Size(), opt.size, opt.digits)
+		sparse := isSparse(fi)
+		if sparse {
+			s = "~" + s
+			if opt.list >= 2 {
+				s += "/" + fmtSize(fi.Blocks()*512, opt.size, opt.digits)
+			}
+		}
+		w := len(s)
+		if fw := opt.size.width(opt.digits); w < fw {
+			s, w = strings.Repeat(" ", fw-w)+s, fw
+		}
+		if sparse && colorSparse != "" {
+			s = colorSparse + s + reset
+		}
+		return s, w
+	}
+}
+
+// Report if the file is sparse: the allocated size is much smaller than the
+// apparent size. This is also true for files on compressed filesystems, but
+// that's okay.
+func isSparse(fi fileInfo) bool {
+	if !fi.Mode().IsRegular() || fi.du != nil {
+		return false
+	}
+	b := fi.Blocks()
+	if b < 0 {
+		return false
+	}
+	sz, alloc := fi.Size(), b*512
+	return sz-alloc >= 4096 && alloc < sz/2
+}
+
+var (
+	sizeSuffixes    = []string{"", "K", "M", "G", "T", "P", "E"}
+	sizeSuffixesSI  = []string{"", "kB", "MB", "GB", "TB", "PB", "EB"}
+	sizeSuffixesIEC = []string{"", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+)
+
+// How to format sizes, from -B, -si, and -iec. The zero value formats as
+// "human" sizes in powers of 1024.
+type sizeFormat struct {
+	blocks  byte   // 's' or 'S' to display the number of blocks.
+	unit    int64  // Display sizes as multiples of unit; 0 for "human" sizes.
+	suffix  string // Display with one decimal and this suffix, rather than the rounded up number of units.
+	si, iec bool   // Use powers of 1000 or KiB/MiB suffixes for "human" sizes.
+}
+
+// Parse a -B value: "s", "S", "h", or an optional number followed by an
+// optional unit, like GNU ls --block-size. Units without a number are
+// displayed with a suffix, and units with a number as the number of blocks:
+//
+//	K, M, G, T, P, E     Powers of 1024
+//	KB, MB, GB, …        Powers of 1000
+//	KiB, MiB, GiB, …     Powers of 1024
+func parseBlockSize(s string, si, iec bool) (sizeFormat, bool) {
+	switch s {
+	case "h":
+		return sizeFormat{si: si, iec: iec}, true
+	case "s", "S":
+		return sizeFormat{blocks: s[0]}, true
+	case "b", "B":
+		return sizeFormat{unit: 1}, true
+	}
+
+	num, unit := s, ""
+	if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i > -1 {
+		num, unit = s[:i], s[i:]
+	}
+	n := int64(1)
+	if num != "" {
+		var err error
+		n, err = strconv.ParseInt(num, 10, 64)
+		if err != nil || n < 1 {
+			return sizeFormat{}, false
+		}
+	}
+	if num == "" && unit == "" {
+		return sizeFormat{}, false
 	}
 
 	var (
-		l = len(i) / 3
-		r = len(i) % 3
-		// +1 for dot, in case of frac. Not a big deal to over-alloc 1 byte.
-		n = make([]byte, 0, len(i)+l+len(frac)+1)
+		mult   = int64(1)
+		suffix string
 	)
-	if r != 0 {
-		n = append(n, i[:r]...)
-		n = append(n, ',')
-	}
-	for j := range l {
-		j++
-		if j > 1 {
-			n = append(n, ',')
+	if unit != "" {
+		p := strings.IndexRune("kmgtpe", unicode.ToLower(rune(unit[0])))
+		if p == -1 {
+			return sizeFormat{}, false
+		}
+		base := int64(1024)
+		switch strings.ToLower(unit[1:]) {
+		case "":
+			suffix = sizeSuffixes[p+1]
+		case "b":
+			base, suffix = 1000, sizeSuffixesSI[p+1]
+		case "ib":
+			suffix = sizeSuffixesIEC[p+1]
+		default:
+			return sizeFormat{}, false
+		}
+		for range p + 1 {
+			mult *= base
 		}
-		n = append(n, i[(j-1)*3+r:j*3+r]...)
 	}
-	if hasFrac {
-		//n = append(n, '.')
-		n = append(n, frac...)
+	if n > math.MaxInt64/mult {
+		return sizeFormat{}, false
 	}
-	return string(n)
+	f := sizeFormat{unit: n * mult}
+	if num == "" {
+		f.suffix = suffix
+	}
+	return f, true
 }
 
-// Only used for the default "-h" sizes
-var testFixedSizeWidth bool
+// Width of "human" sizes; they're always padded to this so they align between
+// listings. Returns 0 for other formats.
+func (f sizeFormat) width(digits *numFormat) int {
+	if f.unit > 0 || f.blocks != 0 {
+		return 0
+	}
+	// "1023" or "10.0" followed by the unit.
+	w := 4 + len(f.suffixes()[1])
+	if digits != nil {
+		w++
+	}
+	return w
+}
 
-func listSize(fi fs.FileInfo, absdir, blockSize string, comma bool) (string, int) {
-	if fi.Size() == -1 {
-		return "???", 3
+func (f sizeFormat) suffixes() []string {
+	switch {
+	case f.si:
+		return sizeSuffixesSI
+	case f.iec:
+		return sizeSuffixesIEC
+	default:
+		return sizeSuffixes
 	}
-	switch blockSize {
-	case "s":
-		s := strconv.FormatInt(os2.Blocks(fi), 10)
-		if comma {
-			s = groupDigits(s)
+}
+
+// Format a size in bytes; the block formats ("s" and "S") are formatted as a
+// "human" size.
+func fmtSize(sz int64, f sizeFormat, digits *numFormat) string {
+	var s string
+	switch {
+	case f.unit == 1:
+		s = strconv.FormatInt(sz, 10)
+	case f.unit > 0 && f.suffix != "":
+		s = shortSize(float64(sz)/float64(f.unit), f.suffix)
+	case f.unit > 0:
+		n := sz / f.unit
+		if sz%f.unit != 0 {
+			n++
 		}
-		return s, len(s)
-	case "S":
-		bs := os2.Blocksize(filepath.Join(absdir, fi.Name()))
-		s := strconv.FormatFloat(math.Ceil(float64(fi.Size())/float64(bs)), 'f', 0, 64)
-		if comma {
-			s = groupDigits(s)
+		s = strconv.FormatInt(n, 10)
+	default:
+		var (
+			base = 1024.0
+			suf  = f.suffixes()
+			n    = float64(sz)
+			i    int
+		)
+		if f.si {
+			base = 1000
 		}
-		return s, len(s)
-	case "b", "B", "1":
-		s := strconv.FormatInt(fi.Size(), 10)
-		if comma {
-			s = groupDigits(s)
+		for n >= base && i < len(suf)-1 {
+			n /= base
+			i++
 		}
-		return s, len(s)
-	case "k", "K":
-		s := shortSize(float64(fi.Size())/1024, "K")
-		if comma {
-			s = groupDigits(s)
+		if i == 0 {
+			s = strconv.FormatInt(sz, 10)
+		} else {
+			s = shortSize(n, suf[i])
 		}
-		return s, len(s)
-	case "m", "M":
-		s := shortSize(float64(fi.Size())/1024/1024, "M")
-		if comma {
-			s = groupDigits(s)
+	}
+	return digits.group(s)
+}
+
+// Summary line for a listing: number of entries per type, the total apparent
+// and allocated size, and how many entries were hidden or filtered.
+func summarize(p printable, opt opts) string {
+	var (
+		types = []struct {
+			one, many string
+			n         int
+		}{
+			{"directory", "directories", 0},
+			{"file", "files", 0},
+			{"symlink", "symlinks", 0},
+			{"pipe", "pipes", 0},
+			{"socket", "sockets", 0},
+			{"block device", "block devices", 0},
+			{"char device", "char devices", 0},
+			{"door", "doors", 0},
+			{"other", "other", 0},
 		}
-		return s, len(s)
-	case "g":
-		s := shortSize(float64(fi.Size())/1024/1024/1024, "G")
-		if comma {
-			s = groupDigits(s)
+		apparent, alloc int64
+	)
+	for _, fi := range p.fi {
+		m := fi.Mode()
+		switch {
+		case m.IsDir():
+			types[0].n++
+		case m.IsRegular():
+			types[1].n++
+		case m&fs.ModeSymlink != 0:
+			types[2].n++
+		case m&fs.ModeNamedPipe != 0:
+			types[3].n++
+		case m&fs.ModeSocket != 0:
+			types[4].n++
+		case m&fs.ModeCharDevice != 0:
+			types[6].n++
+		case m&fs.ModeDevice != 0:
+			types[5].n++
+		case os2.IsDoor(fi):
+			types[7].n++
+		default:
+			types[8].n++
 		}
-		return s, len(s)
-	default:
-		var s string
-		if fi.Size() < 1024 {
-			s = strconv.FormatInt(fi.Size(), 10)
-		} else if fi.Size() < 1024*1024 {
-			s = shortSize(float64(fi.Size())/1024, "K")
-		} else if fi.Size() < 1024*1024*1024 {
-			s = shortSize(float64(fi.Size())/1024/1024, "M")
-		} else {
-			s = shortSize(float64(fi.Size())/1024/1024/1024, "G")
+		if sz := fi.Size(); sz > 0 {
+			apparent += sz
 		}
-		if comma {
-			s = groupDigits(s)
+		if b := fi.Blocks(); b > 0 {
+			alloc += b * 512
 		}
-		if testFixedSizeWidth {
-			return fmt.Sprintf("%5s", s), 5
+	}
+
+	var b strings.Builder
+	for _, t := range types {
+		if t.n == 0 {
+			continue
 		}
-		return s, len(s)
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(plural(t.n, opt.digits, t.one, t.many))
 	}
+	if b.Len() == 0 {
+		b.WriteString("0 entries")
+	}
+	fmt.Fprintf(&b, "; %s apparent, %s allocated",
+		fmtSize(apparent, opt.size, opt.digits), fmtSize(alloc, opt.size, opt.digits))
+	if p.hidden > 0 {
+		fmt.Fprintf(&b, "; %s", plural(p.hidden, opt.digits, "hidden", "hidden"))
+	}
+	if p.filtered > 0 {
+		fmt.Fprintf(&b, "; %s", plural(p.filtered, opt.digits, "filtered", "filtered"))
+	}
+	return b.String()
+}
+
+func plural(n int, digits *numFormat, one, many string) string {
+	s := digits.group(strconv.Itoa(n))
+	if n == 1 {
+		return s + " " + one
+	}
+	return s + " " + many
 }
 
 // FileMode.String() doesn't align nicely with sticky bit and setuid. This ports
@@ -602,99 +1355,51 @@ func owner(absdir string, fi fs.FileInfo, asID bool) (string, string) {
 	if asID {
 		return uid, gid
 	}
+	return userName(uid), groupName(gid)
+}
 
-	var uname, gname string
+func userName(uid string) string {
 	for _, u := range users {
 		if u.uid == uid {
-			uname = u.n
-			break
-		}
-	}
-	for _, g := range groups {
-		if g.gid == gid {
-			gname = g.n
-			break
+			return u.n
 		}
 	}
 
-	if uname == "" {
-		u, err := user.LookupId(uid)
-		if err != nil {
-			u = &user.User{Username: uid}
-			if uid == "" {
-				u.Name = "[failed]"
-			}
+	u, err := user.LookupId(uid)
+	if err != nil {
+		u = &user.User{Username: uid}
+		if uid == "" {
+			u.Name = "[failed]"
 		}
-		// On Windows "well-known sids" aren't mapped by user.LookupId. So copy
-		// what "dir /Q" does.
-		// https://learn.microsoft.com/en-us/windows/win32/secauthz/well-known-sids
-		if runtime.GOOS == "windows" {
-			switch uid {
-			case "S-1-5-32-544":
-				u.Username = `BUILTIN\Administrators`
-
-			}
-		}
-		users = append(users, struct{ uid, n string }{uid, u.Username})
-		uname = u.Username
 	}
+	// On Windows "well-known sids" aren't mapped by user.LookupId. So copy
+	// what "dir /Q" does.
+	// https://learn.microsoft.com/en-us/windows/win32/secauthz/well-known-sids
+	if runtime.GOOS == "windows" {
+		switch uid {
+		case "S-1-5-32-544":
+			u.Username = `BUILTIN\Administrators`
 
-	if gname == "" {
-		g, err := user.LookupGroupId(gid)
-		if err != nil {
-			g = &user.Group{Name: gid}
-			if gid == "" {
-				g.Name = "[failed]"
-			}
 		}
-		groups = append(groups, struct{ gid, n string }{gid, g.Name})
-		gname = g.Name
 	}
-
-	return uname, gname
+	users = append(users, struct{ uid, n string }{uid, u.Username})
+	return u.Username
 }
 
-func printJSON(toPrint []printable, errs *errGroup) {
-	type (
-		E struct {
-			Name       string      `json:"name"`
-			ModTime    time.Time   `json:"mod_time"`
-			BirthTime  time.Time   `json:"birth_time"`
-			AccessTime time.Time   `json:"access_time"`
-			Type       fs.FileMode `json:"type"`
-			Permission fs.FileMode `json:"permission"`
-			Size       int64       `json:"size"`
+func groupName(gid string) string {
+	for _, g := range groups {
+		if g.gid == gid {
+			return g.n
 		}
-		J struct {
-			Dir     string `json:"dir,omitempty"`
-			Error   string `json:"error,omitempty"`
-			AbsDir  string `json:"abs_dir,omitempty"`
-			Entries []E    `json:"entries,omitempty"`
+	}
+
+	g, err := user.LookupGroupId(gid)
+	if err != nil {
+		g = &user.Group{Name: gid}
+		if gid == "" {
+			g.Name = "[failed]"
 		}
-	)
-	var all []J
-	for _, e := range errs.List() {
-		all = append(all, J{Error: e.Error()})
-	}
-	for _, p := range toPrint {
-		cur := J{Dir: p.dir, AbsDir: p.absdir, Entries: make([]E, 0, len(p.fi))}
-		for _, fi := range p.fi {
-			cur.Entries = append(cur.Entries, E{
-				Name:       fi.Name(),
-				ModTime:    fi.ModTime(),
-				BirthTime:  os2.Btime(p.absdir, fi),
-				AccessTime: os2.Atime(fi),
-				Type:       fi.Mode().Type(),
-				Permission: fi.Mode().Perm(),
-				Size:       fi.Size(),
-			})
-		}
-		all = append(all, cur)
-	}
-
-	out, err := json.MarshalIndent(all, "", "  ")
-	zli.F(err)
-	fmt.Fprintln(zli.Stdout, string(out))
-	// err = jfmt.NewFormatter(min(columns, 80), "  ").Format(zli.Stdout, bytes.NewReader(out))
-	// zli.F(err)
+	}
+	groups = append(groups, struct{ gid, n string }{gid, g.Name})
+	return g.Name
 }
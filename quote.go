@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How to quote names, from -Q, -quoting-style, and -quote-for.
+type quoting struct {
+	level int    // Number of times -Q is given; only used if style is "".
+	style string // Quoting style, compatible with GNU ls --quoting-style.
+	shell string // Shell to quote for with the shell styles: "bash", "sh", or "fish".
+}
+
+var (
+	quotingStyles = []string{"literal", "shell", "shell-always", "shell-escape",
+		"shell-escape-always", "c", "escape", "locale"}
+	quotingShells = []string{"bash", "sh", "fish"}
+)
+
+func (q quoting) quote(s string) string {
+	switch q.style {
+	default:
+		return doQuote(s, q.level)
+	case "literal":
+		return replaceUnprintable(s)
+	case "shell", "shell-always":
+		return shellQuote(replaceUnprintable(s), q.style == "shell-always", q.shell)
+	case "shell-escape", "shell-escape-always":
+		return shellQuote(s, q.style == "shell-escape-always", q.shell)
+	case "c":
+		return `"` + cEscape(s, `"`) + `"`
+	case "escape":
+		return cEscape(s, " ")
+	case "locale":
+		if isUTF8Locale() {
+			return "‘" + cEscape(s, "") + "’"
+		}
+		return "'" + cEscape(s, "'") + "'"
+	}
+}
+
+func isUnprintable(r rune, size int) bool {
+	return (r == utf8.RuneError && size == 1) || !unicode.IsPrint(r) || isInvisible(r)
+}
+
+// Replace unprintable characters and invalid bytes with "?", like ls -q.
+func replaceUnprintable(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isUnprintable(r, size) {
+			b.WriteByte('?')
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// Quote s so it can be pasted in a shell. Names are only quoted if they need
+// to be, unless always is set. Unprintable characters are written as escapes
+// for the shell, which are always byte-exact so they don't depend on the
+// locale:
+//
+//	bash   'a'$'\n''b'         Also works in zsh and ksh.
+//	sh     'a'"$(printf '\001')"'b'
+//	fish   'a'\x01'b'
+//
+// POSIX sh doesn't have $'..', so the printf(1) escape is used. This doesn't
+// work for trailing newlines, so newlines are written literally for sh.
+func shellQuote(s string, always bool, shell string) string {
+	type run struct {
+		s   string
+		esc bool
+	}
+	var (
+		runs  []run
+		start int
+		quote = always
+	)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		esc := isUnprintable(r, size) && !(shell == "sh" && r == '\n')
+		if esc || needQuote(r) || r == '\n' {
+			quote = true
+		}
+		if len(runs) == 0 || runs[len(runs)-1].esc != esc {
+			runs = append(runs, run{esc: esc})
+			start = i
+		}
+		runs[len(runs)-1].s = s[start : i+size]
+		i += size
+	}
+	if !quote {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for _, r := range runs {
+		if !r.esc {
+			singleQuote(&b, r.s, shell)
+			continue
+		}
+		switch shell {
+		case "sh":
+			b.WriteString(`"$(printf '`)
+			for i := 0; i < len(r.s); i++ {
+				fmt.Fprintf(&b, `\%03o`, r.s[i])
+			}
+			b.WriteString(`')"`)
+		case "fish":
+			shellEscape(&b, r.s, shell)
+		default:
+			b.WriteString("$'")
+			shellEscape(&b, r.s, shell)
+			b.WriteString("'")
+		}
+	}
+	return b.String()
+}
+
+// Write s in single quotes; a name with ' is written in double quotes if
+// there's nothing else that would be expanded there.
+func singleQuote(b *strings.Builder, s, shell string) {
+	if strings.ContainsRune(s, '\'') && !strings.ContainsAny(s, "\"$`\\!") {
+		b.WriteString(`"` + s + `"`)
+		return
+	}
+	b.WriteByte('\'')
+	if shell == "fish" {
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s))
+	} else {
+		b.WriteString(strings.ReplaceAll(s, `'`, `'\''`))
+	}
+	b.WriteByte('\'')
+}
+
+// Escapes for bash's $'..' and fish, which are the same for what we need,
+// except that fish before 4.0 only accepts \x up to 0x7f and needs \X for
+// other bytes.
+func shellEscape(b *strings.Builder, s, shell string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == 0x1b:
+			b.WriteString(`\e`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c >= 0x80 && shell == "fish":
+			fmt.Fprintf(b, `\X%02x`, c)
+		default:
+			fmt.Fprintf(b, `\x%02x`, c)
+		}
+	}
+}
+
+// Escape s with C escapes: backslash escapes for the well-known control
+// characters, and octal for every byte of other unprintable characters. The
+// characters in extra are also escaped with a backslash.
+func cEscape(s, extra string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\\' || strings.ContainsRune(extra, r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\a':
+			b.WriteString(`\a`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\v':
+			b.WriteString(`\v`)
+		case isUnprintable(r, size):
+			for j := range size {
+				fmt.Fprintf(&b, `\%03o`, s[i+j])
+			}
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func isUTF8Locale() bool {
+	for _, k := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if l := os.Getenv(k); l != "" {
+			l = strings.ToLower(l)
+			return strings.HasSuffix(l, ".utf-8") || strings.HasSuffix(l, ".utf8")
+		}
+	}
+	return false
+}
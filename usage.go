@@ -22,20 +22,70 @@ What to list:
     -H               Follow symlinks of commandline arguments.
     -L               Follow all symlinks.
     -R, -recursive   List subdirectories recursively.
+    -writable        Only list entries the current user can write to. This
+                     applies to every entry: -R still lists the contents of
+                     directories that are filtered, like find -writable.
+    -unwritable      Only list entries the current user can't write to.
     -i, -inode       Print inode numbers.
+    -du              Display the total size of the directory tree for
+                     directories, rather than the size of the directory itself.
+                     This is also used for sorting with -S. Files with more
+                     than one link are counted only once.
+    -x               Don't cross filesystem boundaries for -du. Alias:
+                     -one-file-system.
+    -count           Display the number of entries in directories. Hidden
+                     entries are only counted with -a.
+    -count-split     Like -count, but display the number of directories and
+                     other entries separately.
     -g, -groupname   Always display the group by name in -ll; by default it's
                      only shown if the group group name is different from the
                      username.
 
 How to list it:
 
-    -j, -json        Print as JSON.
+    -j, -json        Print as JSON; see the README for the schema.
+    -ndjson          Print as newline-delimited JSON, writing every directory
+                     as soon as it's read. Every line is a "dir", "entry", or
+                     "error" record.
+    -0, -print0      Print raw paths terminated by a NUL byte, without quoting
+                     or colours, for use with xargs -0 and the like.
+    -abs, -absolute  Print absolute paths with -0.
+    -dired           Print -ll in the format of GNU ls -l --dired, for Emacs
+                     dired: ls-compatible lines followed by the byte offsets of
+                     every name. Set insert-directory-program to elles to use.
+    -format=..       Print the columns as csv or tsv, with a header row. Sizes
+                     are in bytes and times in RFC 3339 format, and there are no
+                     colours; there's a Directory column with -R or more than
+                     one path. markdown (or md) prints a GitHub-flavoured
+                     Markdown table, and html a HTML document with a table
+                     (or list for short listings), with CSS classes named
+                     after LS_COLORS (e.g. "di" for directories) and links
+                     with -hyperlink=always. Anything with {{ is a Go
+                     text/template that's printed for every entry, e.g.:
+                       '{{.Name}}\t{{.Size | human}}\t{{.ModTime | since}}'
+                     The fields are Name, Dir, Path, Type, Mode, Size, Blocks,
+                     Inode, Links, UID, GID, User, Group, ModTime, AccessTime,
+                     BirthTime, LinkTarget, and Hidden. Functions:
+                       human      size as with -B
+                       listSize   size of the entry (.) as with -l
+                       since      relative time: "5 minutes ago"
+                       shortTime  time as with -l
+                       strmode    mode as with -ll
+                       quote      quote as with -Q
+                       color      coloured name of the entry (.); alias: colour
     -l               Long listing with size and mtime; use twice to show more.
     -1               List one path per line; default when stdout is not a tty
     -C               List paths in columns; default when stdout is a tty.
                      Single column (-1) is automatically set for -l, but can be
                      overridden with this.
     -group-dirs      Group directories first. Alias: -group-directories-first.
+    -group=..        Split the listing of every directory in sections:
+                       type    by file type (directories, files, etc.)
+                       ext     by file extension
+                       owner   by the file's owner
+                       mdate   by modification date (today, yesterday, this
+                               week, etc.); uses the creation or access time
+                               with -c or -u.
     -n               Display user an group ID as number, rather than username.
     -w, -width=..    Maximum column width; longer columns will be trimmed. Set
                      to 0 to disable.
@@ -43,6 +93,44 @@ How to list it:
                      too long. This does not set the exact number of columns and
                      sometimes results in more columns.
     -o, -octal       File permissions as octal instead of "rwx…".
+    -bars            Display a bar with the size relative to the largest entry
+                     in the directory. Combine with -du to use the total size
+                     for directories.
+    -bars-total      Like -bars, but relative to the total size of all entries
+                     in the directory.
+    -@, -xattr       List the name and size of extended attributes below every
+                     entry in -l; use twice to also list the values. With -ll
+                     the permissions are followed by a "+" if there's an ACL,
+                     "@" for other extended attributes, or "." if there's only
+                     a security context.
+    -acl             List ACL entries below every entry in -l.
+    -Z, -context     Display the security context (SELinux label).
+    -cap             Display Linux file capabilities, e.g. "cap_net_raw=ep".
+    -flags           Display file flags in -ll: the inode flags as lsattr on
+                     Linux (e.g. "----i---------e-------" for immutable), or
+                     as ls -lo on BSD and macOS (e.g. "schg,nodump").
+    -links           Display the number of hard links. Alias: -nlink.
+    -access          Display the effective permissions for the current user,
+                     e.g. "r-x"; this accounts for groups, ACLs, and read-only
+                     mounts.
+    -fstype          Display the filesystem type, followed by "(ro)" or
+                     "(noexec)" if it's mounted with those options.
+    -mounts          Display the source (e.g. "/dev/sda1") of mount points.
+    -hardlinks       Mark entries that are hard links to the same file with a
+                     number, e.g. "#1" for the first set of hard links. Use
+                     twice to only list the first entry of every set, marked
+                     with the number of entries (e.g. "#1×3").
+    -suspicious      Mark names that may be deceptive and display why: bidi
+                     (text direction overrides), invisible (zero-width
+                     characters), leading-dash, trailing-dot (or space), and
+                     homoglyph (mixed scripts in one word, such as a Cyrillic
+                     "а" in "pаypal", or lookalikes of "/" and "."). These names
+                     are highlighted with a red background.
+    -header          Print a header row with the name of every column.
+    -summary         Print a summary after every directory: the number of
+                     entries per type, the total apparent and allocated size,
+                     and the number of hidden or filtered entries that weren't
+                     listed.
 
 How to format paths:
 
@@ -51,12 +139,27 @@ How to format paths:
     -p               Print / after each directory.
     -F               Print /@*=|> after directory, symlink, executable file,
                      socket, FIFO, or door.
-    -,               (Comma) Print file sizes with thousands separators.
+    -,               (Comma) Print file sizes, inode numbers, and counts with
+                     thousands separators. The separators are taken from
+                     LC_ALL, LC_NUMERIC, or LANG (e.g. "1.234,5" for de_DE,
+                     "12,34,567" for hi_IN).
+    -digits=..       Locale for -,, overriding the environment; e.g. de_DE or
+                     C for "1,234.5". Implies -,.
     -B, -blocks=..   Format for file sizes; as:
                        "1" or "B" for bytes
                        "s" for allocated filesystem blocks
                        "S" for blocks (differs from "s" for sparse files)
-                       unit as K, M, or G (powers of 1024)
+                       unit as K, M, G, T, P, or E (powers of 1024), KB, MB,
+                       etc. (powers of 1000), or KiB, MiB, etc.
+                       number and unit such as 4K or 1M to display the
+                       number of blocks of that size, rounded up.
+                     The default is to display sizes with a unit as needed,
+                     always aligned to the same width.
+    -si              Use powers of 1000 for sizes with a unit (kB, MB, etc.)
+    -iec             Use KiB, MiB, etc. for sizes with a unit.
+                     Sparse files (where the allocated size is much smaller
+                     than the file size) are prefixed with "~" when displaying
+                     sizes with units. -ll also displays the allocated size.
     -c               Use creation ("birth") time for display in -l, and sorting
                      with -t. Does nothing if neither -l nor -t is given.
     -u               Use last access time for display in -l, and sorting
@@ -66,6 +169,13 @@ How to format paths:
                      timezone.
     -Q               Quote paths with special shell characters or spaces; add
                      twice to always quote everything.
+    -quoting-style   Quote paths with the same styles as GNU ls: literal,
+                     shell, shell-always, shell-escape, shell-escape-always, c,
+                     escape, or locale. The QUOTING_STYLE environment variable
+                     is used if neither this nor -Q is given.
+    -quote-for=..    Quote for pasting in a shell: bash (default), sh, or
+                     fish. Sets -quoting-style=shell-escape if no style is
+                     given.
     -trim, -no-trim  Trim pathnames if they're too long to fit on the screen.
                      Only works for interactive terminals or when -w is set.
                      -no-trim turns this off and takes precedence over -trim (so
@@ -84,7 +194,8 @@ Sorting:
     -f               Don't sort, list in directory order. Implies -a.
     -U               Don't sort, list in directory order.
     -sort=..         Sort by …: none (-U), size (-S), time (-t), version (-v),
-                     extension (-X), width (-W)
+                     extension (-X), width (-W), count (number of directory
+                     entries, most first)
 
 Other:
 
@@ -116,7 +227,8 @@ Colours:
     Use LS_COLORS (GNU ls format) or LSCOLORS (BSD ls format) to configure the
     colours. It will try them in that order and use the first one that's found
     (on all platforms). Highlighting paths based on extension ("*.m4a=00;36")
-    isn't implemented.
+    isn't implemented. The "ca" class for files with capabilities and the "mh"
+    class for files with multiple hard links are only used if set in LS_COLORS.
 
     ELLES_COLORS can accept additional :-separated values for elles-specific
     colouring features; this follos the GNU LS_COLORS syntax of «name»=«escape»,
@@ -129,6 +241,17 @@ Colours:
 
                     ELLES_COLORS='bsd:hidden=48;5;255'
 
+        header  Colour for the -header row and -group labels; the default is
+                to underline it.
+
+        sparse  Additional highlights for sparse files, applied to both the
+                size and pathname. The default is to dim them.
+
+        suspicious
+                Additional highlights for names that may be deceptive; see
+                -suspicious. This is always applied if set, also without
+                -suspicious.
+
 Compatibility flags:
 
     -G                Alias for -color=auto.
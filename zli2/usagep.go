@@ -61,7 +61,7 @@ type (
 	}
 )
 
-var reFlag = regexp.MustCompile(`^(?:\t|    )(-(?:[a-zA-Z0-9_.=…-]+|,)(?:, )?)+`)
+var reFlag = regexp.MustCompile(`^(?:\t|    )(-(?:[a-zA-Z0-9_.=…-]+|,|@)(?:, )?)+`)
 
 func Parse(s string) (Usage, error) {
 	var (